@@ -0,0 +1,237 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// ServiceType is the name of the command service type inside
+// a services.Catalog.
+const ServiceType = "command"
+
+// maxOutput limits the captured bytes of stdout and stderr each.
+const maxOutput = 64 * 1024
+
+// Duration is a time.Duration written as text like "1m30s" in JSON.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration has to be a string: %v", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Config defines the command to run. It is also the configuration
+// of the service inside a services.Spec.
+type Config struct {
+	// Path is the name or path of the executable.
+	Path string `json:"path"`
+
+	// Args are the arguments passed to the command.
+	Args []string `json:"args,omitempty"`
+
+	// Env contains additional environment variables as
+	// "KEY=value" added to the one of the process.
+	Env []string `json:"env,omitempty"`
+
+	// Dir is the working directory, by default the one of
+	// the process.
+	Dir string `json:"dir,omitempty"`
+
+	// Timeout limits the execution time, zero means no limit.
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// Output contains the captured output of a command run. The output
+// streams are truncated after 64 KiB each.
+type Output struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+}
+
+// ExitError is returned if a command exits with a non-zero status.
+type ExitError struct {
+	Path     string
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// Error implements the error interface.
+func (e *ExitError) Error() string {
+	output := strings.TrimSpace(e.Stderr)
+	if output == "" {
+		output = strings.TrimSpace(e.Stdout)
+	}
+	if output == "" {
+		return fmt.Sprintf("command %q exited with status %d", e.Path, e.ExitCode)
+	}
+	return fmt.Sprintf("command %q exited with status %d: %s", e.Path, e.ExitCode, output)
+}
+
+// Service implements services.Service running an external command.
+type Service struct {
+	id  string
+	cfg Config
+
+	mu   sync.Mutex
+	last Output
+}
+
+// NewService creates a command service instance.
+func NewService(id string, cfg Config) *Service {
+	return &Service{
+		id:  id,
+		cfg: cfg,
+	}
+}
+
+// ID implements services.Service.
+func (s *Service) ID() string {
+	return s.id
+}
+
+// Type implements services.Typed.
+func (s *Service) Type() string {
+	return ServiceType
+}
+
+// Do implements services.Service.
+func (s *Service) Do() error {
+	return s.DoContext(context.Background())
+}
+
+// DoContext implements services.ContextService. The command is
+// killed when the context is done or the timeout is exceeded. Note
+// that child processes of the command still writing to its output
+// delay the return until they end.
+func (s *Service) DoContext(ctx context.Context) error {
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.cfg.Timeout))
+		defer cancel()
+	}
+	stdout := &limitedBuffer{limit: maxOutput}
+	stderr := &limitedBuffer{limit: maxOutput}
+	cmd := exec.CommandContext(ctx, s.cfg.Path, s.cfg.Args...)
+	cmd.Dir = s.cfg.Dir
+	if len(s.cfg.Env) > 0 {
+		cmd.Env = append(os.Environ(), s.cfg.Env...)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	started := time.Now()
+	err := cmd.Run()
+	output := Output{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: -1,
+		Duration: time.Since(started),
+	}
+	if cmd.ProcessState != nil {
+		output.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	s.mu.Lock()
+	s.last = output
+	s.mu.Unlock()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("command %q aborted: %w", s.cfg.Path, ctxErr)
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return &ExitError{
+			Path:     s.cfg.Path,
+			ExitCode: output.ExitCode,
+			Stdout:   output.Stdout,
+			Stderr:   output.Stderr,
+		}
+	case err != nil:
+		return fmt.Errorf("cannot run command %q: %v", s.cfg.Path, err)
+	}
+	return nil
+}
+
+// LastOutput returns the Output of the latest run.
+func (s *Service) LastOutput() Output {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// RegisterService registers the command service type in the catalog.
+func RegisterService(catalog *services.Catalog) error {
+	return catalog.Register(services.ServiceType{
+		Name:        ServiceType,
+		Description: "Runs an external command with arguments, environment, and timeout.",
+		NewConfig: func() interface{} {
+			return &Config{}
+		},
+		Create: func(id string, config interface{}) (services.Service, error) {
+			cfg := config.(*Config)
+			if cfg.Path == "" {
+				return nil, errors.New("no command path configured")
+			}
+			return NewService(id, *cfg), nil
+		},
+	})
+}
+
+// limitedBuffer stores written bytes up to a limit, further ones
+// are dropped.
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+// Write implements io.Writer.
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if free := lb.limit - lb.buf.Len(); free > 0 {
+		if len(p) > free {
+			lb.buf.Write(p[:free])
+		} else {
+			lb.buf.Write(p)
+		}
+	}
+	// Pretend to write all, the command must not fail.
+	return len(p), nil
+}
+
+// String returns the stored bytes as string.
+func (lb *limitedBuffer) String() string {
+	return lb.buf.String()
+}
@@ -0,0 +1,145 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+//go:build !windows
+// +build !windows
+
+package command_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/command"
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestServiceOutput verifies running a command with arguments,
+// environment, and working directory.
+func TestServiceOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "command")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	svc := command.NewService("cmd", command.Config{
+		Path: "sh",
+		Args: []string{"-c", `echo "$GREETING $1"; pwd`, "sh", "world"},
+		Env:  []string{"GREETING=hello"},
+		Dir:  dir,
+	})
+
+	if err := svc.Do(); err != nil {
+		t.Fatalf("running command failed: %v", err)
+	}
+	output := svc.LastOutput()
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatalf("cannot evaluate directory: %v", err)
+	}
+	expected := "hello world\n" + realDir + "\n"
+	if output.Stdout != expected || output.ExitCode != 0 {
+		t.Fatalf("unexpected output: %+v", output)
+	}
+}
+
+// TestServiceExitStatus verifies the mapping of a non-zero exit
+// status to an error containing the output.
+func TestServiceExitStatus(t *testing.T) {
+	svc := command.NewService("cmd", command.Config{
+		Path: "sh",
+		Args: []string{"-c", "echo working; echo broken >&2; exit 3"},
+	})
+
+	err := svc.Do()
+	var exitErr *command.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected exit error, got %v", err)
+	}
+	if exitErr.ExitCode != 3 || exitErr.Stdout != "working\n" || exitErr.Stderr != "broken\n" {
+		t.Fatalf("unexpected exit error: %+v", exitErr)
+	}
+	if !strings.Contains(err.Error(), "exited with status 3: broken") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+
+	err = command.NewService("cmd", command.Config{Path: "does-not-exist"}).Do()
+	if err == nil || errors.As(err, &exitErr) {
+		t.Fatalf("expected start error, got %v", err)
+	}
+}
+
+// TestServiceTimeout verifies the killing of commands exceeding
+// their timeout or being cancelled.
+func TestServiceTimeout(t *testing.T) {
+	svc := command.NewService("cmd", command.Config{
+		Path:    "sleep",
+		Args:    []string{"5"},
+		Timeout: command.Duration(20 * time.Millisecond),
+	})
+	started := time.Now()
+	err := svc.Do()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(started) > time.Second {
+		t.Fatalf("command has not been killed in time")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	svc = command.NewService("cmd", command.Config{
+		Path: "sleep",
+		Args: []string{"5"},
+	})
+	if err := svc.DoContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+// TestRegisterService verifies the registration of the command
+// service type and the creation of services by their specs.
+func TestRegisterService(t *testing.T) {
+	catalog := services.NewCatalog()
+	if err := command.RegisterService(catalog); err != nil {
+		t.Fatalf("registering service failed: %v", err)
+	}
+	svc, err := catalog.Create(services.Spec{
+		ServiceID: "cmd",
+		Type:      command.ServiceType,
+		Config:    json.RawMessage(`{"path":"sh","args":["-c","exit 0"],"timeout":"1s"}`),
+	})
+	if err != nil {
+		t.Fatalf("creating service failed: %v", err)
+	}
+	if err := svc.Do(); err != nil {
+		t.Fatalf("executing service failed: %v", err)
+	}
+	_, err = catalog.Create(services.Spec{
+		ServiceID: "cmd",
+		Type:      command.ServiceType,
+		Config:    json.RawMessage(`{"path":"sh","timeout":"soon"}`),
+	})
+	if err == nil {
+		t.Fatalf("creating service with invalid timeout did not fail")
+	}
+	_, err = catalog.Create(services.Spec{
+		ServiceID: "cmd",
+		Type:      command.ServiceType,
+		Config:    json.RawMessage(`{}`),
+	})
+	if err == nil {
+		t.Fatalf("creating service without path did not fail")
+	}
+}
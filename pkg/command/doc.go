@@ -0,0 +1,11 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+// Package command provides a service running external commands,
+// e.g. shell scripts. Their output is captured and returned
+// in case of a failure.
+package command
@@ -11,4 +11,5 @@ type Consumer struct {
 	ID   string
 	Key  []byte
 	Name string
+	Plan string
 }
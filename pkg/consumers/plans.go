@@ -0,0 +1,54 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+package consumers
+
+// Plan defines the entitlements of the consumers having this plan,
+// e.g. "free", "standard", or "premium".
+type Plan struct {
+	Name string
+
+	// ServiceTypes contains the types of services the consumers
+	// may book. If empty all services are allowed.
+	ServiceTypes []string
+
+	// MaxBookings is the maximum number of services a consumer
+	// may book at the same time. Zero means no limit.
+	MaxBookings int
+}
+
+// Allows checks if the Plan allows booking services of the
+// given type. Services without a type are only allowed if the
+// plan does not restrict the types.
+func (p Plan) Allows(svcType string) bool {
+	if len(p.ServiceTypes) == 0 {
+		return true
+	}
+	for _, allowed := range p.ServiceTypes {
+		if allowed == svcType {
+			return true
+		}
+	}
+	return false
+}
+
+// Plans contains Plans by their name.
+type Plans map[string]Plan
+
+// NewPlans creates Plans containing the given ones.
+func NewPlans(plans ...Plan) Plans {
+	ps := make(Plans, len(plans))
+	for _, plan := range plans {
+		ps[plan.Name] = plan
+	}
+	return ps
+}
+
+// Of returns the Plan of the Consumer.
+func (ps Plans) Of(c Consumer) (Plan, bool) {
+	plan, ok := ps[c.Plan]
+	return plan, ok
+}
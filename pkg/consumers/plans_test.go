@@ -0,0 +1,44 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+package consumers_test
+
+import (
+	"testing"
+
+	"github.com/themue/samples/pkg/consumers"
+)
+
+// TestPlans tests the lookup of plans and their allowed service types.
+func TestPlans(t *testing.T) {
+	plans := consumers.NewPlans(
+		consumers.Plan{Name: "free", ServiceTypes: []string{"metaweather"}, MaxBookings: 1},
+		consumers.Plan{Name: "premium"},
+	)
+
+	free, ok := plans.Of(consumers.Consumer{ID: "C0", Plan: "free"})
+	if !ok {
+		t.Fatalf("plan free not found")
+	}
+	if !free.Allows("metaweather") {
+		t.Errorf("plan free does not allow metaweather")
+	}
+	if free.Allows("exec") || free.Allows("") {
+		t.Errorf("plan free allows too much")
+	}
+
+	premium, ok := plans.Of(consumers.Consumer{ID: "C1", Plan: "premium"})
+	if !ok {
+		t.Fatalf("plan premium not found")
+	}
+	if !premium.Allows("exec") || !premium.Allows("") {
+		t.Errorf("plan premium does not allow all types")
+	}
+
+	if _, ok := plans.Of(consumers.Consumer{ID: "C2"}); ok {
+		t.Errorf("consumer without plan has a plan")
+	}
+}
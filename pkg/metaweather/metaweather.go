@@ -7,10 +7,12 @@
 package metaweather
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 )
 
 const (
@@ -18,19 +20,70 @@ const (
 	readURL  = "https://www.metaweather.com/api/location/%d/"
 )
 
-// QueryLocations queries MetaWeather for locations with matching titles
-// or title parts.
-func QueryLocations(query string) (Locations, error) {
-	url := fmt.Sprintf(queryURL, query)
-	resp, err := http.Get(url)
+// client is used for all requests, its timeout ensures that hanging
+// connections don't block the callers forever.
+var client = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// retryableError marks errors of temporary problems when accessing
+// MetaWeather. It implements services.RetryableError, so that failed
+// services are retried.
+type retryableError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the marked error.
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// Retryable implements services.RetryableError.
+func (e *retryableError) Retryable() bool {
+	return true
+}
+
+// get performs the HTTP GET request and returns the body. Network
+// errors and server side errors are marked as retryable.
+func get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("cannot send MetaWeather query: %v", err)
+		return nil, fmt.Errorf("cannot create request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &retryableError{err}
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, &retryableError{fmt.Errorf("server responded %q", resp.Status)}
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("cannot retrieve body: %v", err)
+		return nil, &retryableError{fmt.Errorf("cannot retrieve body: %v", err)}
+	}
+	return body, nil
+}
+
+// QueryLocations queries MetaWeather for locations with matching titles
+// or title parts.
+func QueryLocations(query string) (Locations, error) {
+	return queryLocations(context.Background(), query)
+}
+
+// queryLocations queries the locations until the context is done.
+func queryLocations(ctx context.Context, query string) (Locations, error) {
+	url := fmt.Sprintf(queryURL, query)
+	body, err := get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("cannot send MetaWeather query: %w", err)
 	}
 
 	var locations Locations
@@ -46,18 +99,17 @@ func QueryLocations(query string) (Locations, error) {
 // ReadWeather retrieves the location and weather information for the
 // given Where On Earth ID.
 func ReadWeather(woeid int) (Weather, error) {
+	return readWeather(context.Background(), woeid)
+}
+
+// readWeather retrieves the weather until the context is done.
+func readWeather(ctx context.Context, woeid int) (Weather, error) {
 	var weather Weather
 
 	url := fmt.Sprintf(readURL, woeid)
-	resp, err := http.Get(url)
-	if err != nil {
-		return weather, fmt.Errorf("cannot read weather: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := get(ctx, url)
 	if err != nil {
-		return weather, fmt.Errorf("cannot retrieve body: %v", err)
+		return weather, fmt.Errorf("cannot read weather: %w", err)
 	}
 
 	err = json.Unmarshal(body, &weather)
@@ -6,7 +6,23 @@
 // by the new BSD license.
 package metaweather
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// ServiceType is the name of the MetaWeather service type inside
+// a services.Catalog.
+const ServiceType = "metaweather"
+
+// Config is the configuration of a MetaWeather service inside
+// a services.Spec.
+type Config struct {
+	Names []string `json:"names"`
+}
 
 // Callback defines what has to be passed to a new Service
 // to handle retrieved Weathers.
@@ -42,12 +58,55 @@ func (s *Service) ID() string {
 	return s.id
 }
 
+// Type implements services.Typed, so that the Service can be
+// booked by consumers whose plan allows the MetaWeather type.
+func (s *Service) Type() string {
+	return ServiceType
+}
+
 // Do implements services.Service.
 func (s *Service) Do() error {
-	ws := s.sub.Fetch(s.names...)
-	err := s.callback(ws)
+	return s.DoContext(context.Background())
+}
+
+// DoContext implements services.ContextService. Waiting for the
+// Subscriber is interrupted when the context is done, and the
+// callback will not be called anymore.
+func (s *Service) DoContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("executing MetaWeather service cancelled: %v", err)
+	}
+	ws, err := s.sub.FetchContext(ctx, s.names...)
 	if err != nil {
-		return fmt.Errorf("executing MetaWeather service failed: %v", err)
+		return fmt.Errorf("executing MetaWeather service failed: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("executing MetaWeather service cancelled: %v", err)
+	}
+	err = s.callback(ws)
+	if err != nil {
+		// Wrap to keep a possible retryable marker of the callback.
+		return fmt.Errorf("executing MetaWeather service failed: %w", err)
 	}
 	return nil
 }
+
+// RegisterService registers the MetaWeather service type in the
+// catalog. The created services share the Subscriber and the
+// Callback, only the location names are configured per service.
+func RegisterService(catalog *services.Catalog, sub *Subscriber, callback Callback) error {
+	return catalog.Register(services.ServiceType{
+		Name:        ServiceType,
+		Description: "Passes the weather of the configured locations to a callback.",
+		NewConfig: func() interface{} {
+			return &Config{}
+		},
+		Create: func(id string, config interface{}) (services.Service, error) {
+			cfg := config.(*Config)
+			if len(cfg.Names) == 0 {
+				return nil, errors.New("no location names configured")
+			}
+			return NewService(id, sub, callback, cfg.Names...), nil
+		},
+	})
+}
@@ -0,0 +1,83 @@
+// The Samples Project
+//
+// Copyright 2020 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+package metaweather_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/consumers"
+	"github.com/themue/samples/pkg/metaweather"
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestRegisterService verifies the registration of the MetaWeather
+// service type and the creation of services by their specs.
+func TestRegisterService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := metaweather.StartSubscriber(ctx, time.Minute)
+	fetched := make(chan int, 1)
+	callback := func(ws []metaweather.Weather) error {
+		fetched <- len(ws)
+		return nil
+	}
+	catalog := services.NewCatalog()
+
+	err := metaweather.RegisterService(catalog, sub, callback)
+	if err != nil {
+		t.Fatalf("registering service failed: %v", err)
+	}
+	svc, err := catalog.Create(services.Spec{
+		ServiceID: "weather",
+		Type:      metaweather.ServiceType,
+		Config:    json.RawMessage(`{"names":["london"]}`),
+	})
+	if err != nil {
+		t.Fatalf("creating service failed: %v", err)
+	}
+	if err := svc.Do(); err != nil {
+		t.Fatalf("executing service failed: %v", err)
+	}
+	// Nothing subscribed, so nothing fetched.
+	if n := <-fetched; n != 0 {
+		t.Fatalf("illegal number of weathers: %d", n)
+	}
+	_, err = catalog.Create(services.Spec{
+		ServiceID: "weather",
+		Type:      metaweather.ServiceType,
+		Config:    json.RawMessage(`{"names":[]}`),
+	})
+	if err == nil {
+		t.Fatalf("creating service without names did not fail")
+	}
+}
+
+// TestBookTypedService verifies that a Service is booked under a
+// plan allowing only the MetaWeather type.
+func TestBookTypedService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := metaweather.StartSubscriber(ctx, time.Minute)
+	cc := consumers.StartController(ctx, consumers.StartInMemoryStore(ctx))
+	if err := cc.Add(consumers.Consumer{ID: "foo", Plan: "weather"}); err != nil {
+		t.Fatalf("adding consumer failed: %v", err)
+	}
+	plans := consumers.NewPlans(
+		consumers.Plan{Name: "weather", ServiceTypes: []string{metaweather.ServiceType}},
+	)
+	p := services.StartProvider(ctx, services.WithPlans(cc, plans))
+
+	svc := metaweather.NewService("weather", sub, func([]metaweather.Weather) error {
+		return nil
+	}, "london")
+	if n, err := p.Book("foo", svc); err != nil || n != 1 {
+		t.Fatalf("booking returned %d bookings and error %v", n, err)
+	}
+}
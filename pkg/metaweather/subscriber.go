@@ -8,6 +8,7 @@ package metaweather
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"strings"
 	"time"
@@ -21,6 +22,8 @@ type Subscriber struct {
 	actionc   chan func()
 	locations map[string]int
 	weathers  map[int]Weather
+	failures  map[int]error
+	updating  map[int]bool
 }
 
 // StartSubscriber makes the Subscriber run in the background.
@@ -31,6 +34,8 @@ func StartSubscriber(ctx context.Context, interval time.Duration) *Subscriber {
 		actionc:   make(chan func(), 16),
 		locations: make(map[string]int),
 		weathers:  make(map[int]Weather),
+		failures:  make(map[int]error),
+		updating:  make(map[int]bool),
 	}
 	go s.backend()
 	return s
@@ -42,7 +47,7 @@ func (s *Subscriber) Subscribe(query string) []string {
 	names := []string{}
 
 	s.doSync(func() {
-		locations, err := QueryLocations(query)
+		locations, err := queryLocations(s.ctx, query)
 		if err != nil {
 			log.Printf("query of %q failed: %v", query, err)
 			return
@@ -53,11 +58,14 @@ func (s *Subscriber) Subscribe(query string) []string {
 			names = append(names, name)
 			if _, ok := s.weathers[location.WOEID]; !ok {
 				// It's new, so add it.
-				weather, err := ReadWeather(location.WOEID)
+				weather, err := readWeather(s.ctx, location.WOEID)
 				if err != nil {
+					// Keep the failure, the location will be updated later.
 					log.Printf("subscription of %q failed: %v", name, err)
+					s.failures[location.WOEID] = err
 					continue
 				}
+				delete(s.failures, location.WOEID)
 				s.weathers[location.WOEID] = weather
 				log.Printf("subscribed %q", name)
 			}
@@ -70,19 +78,46 @@ func (s *Subscriber) Subscribe(query string) []string {
 // Fetch retrieves a number of Weathers. Any so far unsubscribed name
 // will be ignored.
 func (s *Subscriber) Fetch(names ...string) []Weather {
+	weathers, err := s.FetchContext(context.Background(), names...)
+	if err != nil {
+		return []Weather{}
+	}
+	return weathers
+}
+
+// FetchContext retrieves a number of Weathers like Fetch. It stops
+// waiting for the Subscriber and returns an error when the context
+// or the one of the Subscriber is done. It also returns the error
+// of the latest failed reading of a location, in this case a new
+// reading is started.
+func (s *Subscriber) FetchContext(ctx context.Context, names ...string) ([]Weather, error) {
 	weathers := []Weather{}
+	var ferr error
 
-	s.doSync(func() {
+	err := s.doSyncContext(ctx, func() {
 		for _, name := range names {
 			woeid, ok := s.locations[strings.ToLower(name)]
 			if !ok {
 				continue
 			}
+			if err, failed := s.failures[woeid]; failed {
+				if ferr == nil {
+					ferr = fmt.Errorf("weather of %q is not available: %w", name, err)
+				}
+				s.update(woeid)
+				continue
+			}
 			weathers = append(weathers, s.weathers[woeid])
 		}
 	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch weathers: %w", err)
+	}
+	if ferr != nil {
+		return nil, fmt.Errorf("cannot fetch weathers: %w", ferr)
+	}
 
-	return weathers
+	return weathers, nil
 }
 
 // doSync sends an action for execution to the backend and waits
@@ -99,11 +134,36 @@ func (s *Subscriber) doSync(action func()) {
 	<-donec
 }
 
+// doSyncContext sends an action for execution to the backend and waits
+// until its done or one of the contexts is done.
+func (s *Subscriber) doSyncContext(ctx context.Context, action func()) error {
+	donec := make(chan struct{})
+
+	select {
+	case s.actionc <- func() {
+		action()
+		close(donec)
+	}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+
+	select {
+	case <-donec:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
 // backend is the goroutine of the Subscriber.
 func (s *Subscriber) backend() {
 	ticker := time.NewTicker(s.interval)
 	defer ticker.Stop()
-	defer close(s.actionc)
 	for {
 		select {
 		case <-s.ctx.Done():
@@ -121,21 +181,39 @@ func (s *Subscriber) backend() {
 // the subscriber is not blocked.
 func (s *Subscriber) updateAll() {
 	for woeid := range s.weathers {
-		go s.updateOne(woeid)
+		s.update(woeid)
+	}
+	for woeid := range s.failures {
+		s.update(woeid)
+	}
+}
+
+// update starts the goroutine updating one location if it's not
+// already running.
+func (s *Subscriber) update(woeid int) {
+	if s.updating[woeid] {
+		return
 	}
+	s.updating[woeid] = true
+	go s.updateOne(woeid)
 }
 
-// updateOne updates the weather data for one location.
+// updateOne updates the weather data for one location. A failure
+// is kept to be returned when fetching the location.
 func (s *Subscriber) updateOne(woeid int) {
-	s.actionc <- func() {
-		name := s.weathers[woeid].Title
-		log.Printf("updating weather of %q...", name)
-		weather, err := ReadWeather(woeid)
+	log.Printf("updating weather of %d...", woeid)
+	weather, err := readWeather(s.ctx, woeid)
+	select {
+	case s.actionc <- func() {
+		delete(s.updating, woeid)
 		if err != nil {
-			// Don't care a lot, just log it.
-			log.Printf("updating weather of %q failed: %v", name, err)
+			log.Printf("updating weather of %d failed: %v", woeid, err)
+			s.failures[woeid] = err
 			return
 		}
+		delete(s.failures, woeid)
 		s.weathers[woeid] = weather
+	}:
+	case <-s.ctx.Done():
 	}
 }
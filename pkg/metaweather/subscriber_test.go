@@ -8,6 +8,7 @@ package metaweather_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -55,3 +56,28 @@ func TestUpdates(t *testing.T) {
 		t.Fatalf("time #1 (%v) has to be different from time #2 (%v)", t1, t2)
 	}
 }
+
+// TestFetchContext verifies that fetching does not block when the
+// Subscriber or the passed context is done.
+func TestFetchContext(t *testing.T) {
+	subCtx, subCancel := context.WithCancel(context.Background())
+	sub := metaweather.StartSubscriber(subCtx, time.Minute)
+	subCancel()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := sub.FetchContext(ctx, "london"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled subscriber, got %v", err)
+	}
+	if weathers := sub.Fetch("london"); len(weathers) != 0 {
+		t.Fatalf("illegal number of cities: %v", weathers)
+	}
+	svc := metaweather.NewService("weather", sub, func([]metaweather.Weather) error {
+		t.Fatalf("callback must not be called")
+		return nil
+	}, "london")
+	if err := svc.DoContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled service, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/themue/samples/pkg/consumers"
+)
+
+// ErrUnauthenticated is returned if a consumer is not authenticated.
+var ErrUnauthenticated = errors.New("consumer not authenticated")
+
+// Authenticator authenticates consumers by their ID and key. It
+// is implemented by the consumers.Controller.
+type Authenticator interface {
+	Authenticate(id string, key []byte) (consumers.Consumer, error)
+}
+
+// WithAuthenticator lets the Provider require authenticated consumers.
+// Booking, unbooking, and spawning then have to be done by a Session
+// returned by Provider.Authenticate.
+func WithAuthenticator(auth Authenticator) Option {
+	return func(p *Provider) {
+		p.auth = auth
+	}
+}
+
+// requireSession returns an error if the Provider has an
+// Authenticator and so operations need a Session.
+func (p *Provider) requireSession() error {
+	if p.auth != nil {
+		return fmt.Errorf("%w: session required", ErrUnauthenticated)
+	}
+	return nil
+}
+
+// Session allows an authenticated consumer to book, unbook, and
+// spawn its services.
+type Session struct {
+	p        *Provider
+	consumer consumers.Consumer
+}
+
+// Authenticate authenticates a consumer and returns a Session for
+// it. Unknown consumers and invalid keys are rejected. Without an
+// Authenticator any consumer ID is accepted.
+func (p *Provider) Authenticate(consumerID string, key []byte) (*Session, error) {
+	c := consumers.Consumer{ID: consumerID}
+	if p.auth != nil {
+		var err error
+		c, err = p.auth.Authenticate(consumerID, key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+		}
+	} else if p.reader != nil {
+		var err error
+		c, err = p.reader.Read(consumerID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+		}
+	}
+	return &Session{
+		p:        p,
+		consumer: c,
+	}, nil
+}
+
+// Consumer returns the authenticated consumer.
+func (s *Session) Consumer() consumers.Consumer {
+	return s.consumer
+}
+
+// Book assigns Services to the consumer like Provider.Book.
+func (s *Session) Book(svcs ...Service) (int, error) {
+	plan, err := s.p.planFor(s.consumer)
+	if err != nil {
+		return 0, fmt.Errorf("cannot book services for consumer %q: %w", s.consumer.ID, err)
+	}
+	return s.p.bookServices(s.consumer.ID, plan, svcs...)
+}
+
+// BookSpecs books Services by their Specs like Provider.BookSpecs.
+func (s *Session) BookSpecs(specs ...Spec) (int, error) {
+	plan, err := s.p.planFor(s.consumer)
+	if err != nil {
+		return 0, fmt.Errorf("cannot book specs for consumer %q: %w", s.consumer.ID, err)
+	}
+	return s.p.bookSpecs(s.consumer.ID, plan, specs...)
+}
+
+// Unbook drops assignments of Services like Provider.Unbook.
+func (s *Session) Unbook(svcIDs ...string) (int, error) {
+	return s.p.unbook(s.consumer.ID, svcIDs...)
+}
+
+// Spawn runs the booked services like Provider.Spawn.
+func (s *Session) Spawn() (*Spawning, error) {
+	return s.SpawnContext(context.Background())
+}
+
+// SpawnContext runs the booked services like Provider.SpawnContext.
+func (s *Session) SpawnContext(ctx context.Context) (*Spawning, error) {
+	return s.p.spawnContext(ctx, s.consumer.ID, nil)
+}
+
+// ReplayAll executes the services of the dead letters of the
+// consumer again like Provider.ReplayAll.
+func (s *Session) ReplayAll() ([]*Spawning, error) {
+	if s.consumer.ID == "" {
+		return nil, fmt.Errorf("cannot replay dead letters: %w", ErrUnauthenticated)
+	}
+	return s.p.replay(s.consumer.ID)
+}
+
+// Spawner returns a Spawner for a Scheduler spawning the services
+// of the authenticated consumer. Other consumer IDs are rejected.
+func (s *Session) Spawner() Spawner {
+	return SpawnerFunc(func(consumerID string) (*Spawning, error) {
+		if consumerID != s.consumer.ID {
+			return nil, fmt.Errorf("cannot spawn services of consumer %q: %w", consumerID, ErrUnauthenticated)
+		}
+		return s.Spawn()
+	})
+}
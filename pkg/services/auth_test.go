@@ -0,0 +1,85 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/themue/samples/pkg/consumers"
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestProviderAuthentication validates that a Provider with an
+// Authenticator only accepts operations of Sessions.
+func TestProviderAuthentication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cc := consumers.StartController(ctx, consumers.StartInMemoryStore(ctx))
+	if err := cc.Add(consumers.Consumer{ID: "c", Key: []byte("secret"), Plan: "free"}); err != nil {
+		t.Fatalf("adding consumer failed: %v", err)
+	}
+	p := services.StartProvider(ctx,
+		services.WithAuthenticator(cc),
+		services.WithPlans(cc, consumers.NewPlans(consumers.Plan{Name: "free", MaxBookings: 1})),
+		services.WithDeadLetterQueue(services.NewDeadLetterQueue(0)),
+	)
+
+	if _, err := p.Book("c", echoService{id: "a"}); !errors.Is(err, services.ErrUnauthenticated) {
+		t.Fatalf("expected unauthenticated error for booking, got %v", err)
+	}
+	if _, err := p.Unbook("c", "a"); !errors.Is(err, services.ErrUnauthenticated) {
+		t.Fatalf("expected unauthenticated error for unbooking, got %v", err)
+	}
+	if _, err := p.Spawn("c"); !errors.Is(err, services.ErrUnauthenticated) {
+		t.Fatalf("expected unauthenticated error for spawning, got %v", err)
+	}
+	if _, err := p.ReplayAll("c"); !errors.Is(err, services.ErrUnauthenticated) {
+		t.Fatalf("expected unauthenticated error for replaying, got %v", err)
+	}
+	if _, err := p.Replay("dl-1"); !errors.Is(err, services.ErrUnauthenticated) {
+		t.Fatalf("expected unauthenticated error for replaying, got %v", err)
+	}
+	if _, err := p.Authenticate("c", []byte("wrong")); !errors.Is(err, services.ErrUnauthenticated) {
+		t.Fatalf("expected unauthenticated error for invalid key, got %v", err)
+	}
+	if _, err := p.Authenticate("unknown", []byte("secret")); !errors.Is(err, services.ErrUnauthenticated) {
+		t.Fatalf("expected unauthenticated error for unknown consumer, got %v", err)
+	}
+
+	s, err := p.Authenticate("c", []byte("secret"))
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	if s.Consumer().ID != "c" {
+		t.Fatalf("session has consumer %q", s.Consumer().ID)
+	}
+	if n, err := s.Book(echoService{id: "a"}); err != nil || n != 1 {
+		t.Fatalf("booking returned %d bookings and error %v", n, err)
+	}
+	if _, err := s.Book(echoService{id: "b"}); !errors.Is(err, services.ErrNotEntitled) {
+		t.Fatalf("expected entitlement error, got %v", err)
+	}
+	spawning, err := s.Spawn()
+	if err != nil {
+		t.Fatalf("spawning failed: %v", err)
+	}
+	if results := spawning.Wait(); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	if spawnings, err := s.ReplayAll(); err != nil || len(spawnings) != 0 {
+		t.Fatalf("replaying returned %d spawnings and error %v", len(spawnings), err)
+	}
+	if _, err := s.Spawner().Spawn("other"); !errors.Is(err, services.ErrUnauthenticated) {
+		t.Fatalf("expected unauthenticated error for other consumer, got %v", err)
+	}
+	if n, err := s.Unbook("a"); err != nil || n != 0 {
+		t.Fatalf("unbooking returned %d bookings and error %v", n, err)
+	}
+}
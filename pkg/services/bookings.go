@@ -0,0 +1,241 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// --------------------------------------------------
+// Interface for booking store backends.
+// --------------------------------------------------
+
+// BookingStore defines the interface for persisting the bookings
+// of a Provider.
+type BookingStore interface {
+	// Put stores the booked Specs of a consumer, replacing
+	// the former ones.
+	Put(consumerID string, specs []Spec) error
+
+	// Delete removes the bookings of a consumer.
+	Delete(consumerID string) error
+
+	// All retrieves the bookings of all consumers.
+	All() (map[string][]Spec, error)
+}
+
+// --------------------------------------------------
+// In-memory booking store.
+// --------------------------------------------------
+
+// inMemoryBookingStore keeps the bookings in memory, e.g. for tests.
+type inMemoryBookingStore struct {
+	ctx      context.Context
+	actionC  chan func()
+	bookings map[string][]Spec
+}
+
+// StartInMemoryBookingStore creates a BookingStore running simply
+// in memory.
+func StartInMemoryBookingStore(ctx context.Context) BookingStore {
+	s := &inMemoryBookingStore{
+		ctx:      ctx,
+		actionC:  make(chan func(), 1),
+		bookings: make(map[string][]Spec),
+	}
+	go s.backend()
+	return s
+}
+
+// Put implements BookingStore.
+func (s *inMemoryBookingStore) Put(consumerID string, specs []Spec) error {
+	s.doSync(func() {
+		s.bookings[consumerID] = append([]Spec(nil), specs...)
+	})
+	return nil
+}
+
+// Delete implements BookingStore.
+func (s *inMemoryBookingStore) Delete(consumerID string) error {
+	s.doSync(func() {
+		delete(s.bookings, consumerID)
+	})
+	return nil
+}
+
+// All implements BookingStore.
+func (s *inMemoryBookingStore) All() (map[string][]Spec, error) {
+	all := make(map[string][]Spec)
+	s.doSync(func() {
+		for consumerID, specs := range s.bookings {
+			all[consumerID] = append([]Spec(nil), specs...)
+		}
+	})
+	return all, nil
+}
+
+// doSync sends an action for execution to the backend and waits
+// until its done.
+func (s *inMemoryBookingStore) doSync(action func()) {
+	doneC := make(chan struct{})
+
+	s.actionC <- func() {
+		action()
+		close(doneC)
+	}
+
+	<-doneC
+}
+
+// backend is the goroutine of the inMemoryBookingStore.
+func (s *inMemoryBookingStore) backend() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case action := <-s.actionC:
+			action()
+		}
+	}
+}
+
+// --------------------------------------------------
+// File-backed booking store.
+// --------------------------------------------------
+
+// fileBookingStore keeps the bookings in a JSON file.
+type fileBookingStore struct {
+	ctx      context.Context
+	actionC  chan func()
+	path     string
+	bookings map[string][]Spec
+}
+
+// StartFileBookingStore creates a BookingStore persisting the
+// bookings as JSON in the file with the given path. Already
+// existing bookings are read at start.
+func StartFileBookingStore(ctx context.Context, path string) (BookingStore, error) {
+	s := &fileBookingStore{
+		ctx:      ctx,
+		actionC:  make(chan func(), 1),
+		path:     path,
+		bookings: make(map[string][]Spec),
+	}
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("cannot read bookings file: %v", err)
+	default:
+		if err := json.Unmarshal(data, &s.bookings); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal bookings file: %v", err)
+		}
+	}
+	go s.backend()
+	return s, nil
+}
+
+// Put implements BookingStore.
+func (s *fileBookingStore) Put(consumerID string, specs []Spec) error {
+	var err error
+	s.doSync(func() {
+		old, ok := s.bookings[consumerID]
+		s.bookings[consumerID] = append([]Spec(nil), specs...)
+		if err = s.write(); err != nil {
+			// Keep file and memory in sync.
+			if ok {
+				s.bookings[consumerID] = old
+			} else {
+				delete(s.bookings, consumerID)
+			}
+		}
+	})
+	return err
+}
+
+// Delete implements BookingStore.
+func (s *fileBookingStore) Delete(consumerID string) error {
+	var err error
+	s.doSync(func() {
+		old, ok := s.bookings[consumerID]
+		if !ok {
+			return
+		}
+		delete(s.bookings, consumerID)
+		if err = s.write(); err != nil {
+			s.bookings[consumerID] = old
+		}
+	})
+	return err
+}
+
+// All implements BookingStore.
+func (s *fileBookingStore) All() (map[string][]Spec, error) {
+	all := make(map[string][]Spec)
+	s.doSync(func() {
+		for consumerID, specs := range s.bookings {
+			all[consumerID] = append([]Spec(nil), specs...)
+		}
+	})
+	return all, nil
+}
+
+// write writes the bookings into a temporary file and renames it
+// afterwards, so that the file is never written partly.
+func (s *fileBookingStore) write() error {
+	data, err := json.MarshalIndent(s.bookings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal bookings: %v", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot create bookings file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write bookings file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write bookings file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("cannot replace bookings file: %v", err)
+	}
+	return nil
+}
+
+// doSync sends an action for execution to the backend and waits
+// until its done.
+func (s *fileBookingStore) doSync(action func()) {
+	doneC := make(chan struct{})
+
+	s.actionC <- func() {
+		action()
+		close(doneC)
+	}
+
+	<-doneC
+}
+
+// backend is the goroutine of the fileBookingStore.
+func (s *fileBookingStore) backend() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case action := <-s.actionC:
+			action()
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestInMemoryBookingStore validates storing bookings in memory.
+func TestInMemoryBookingStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := services.StartInMemoryBookingStore(ctx)
+
+	testBookingStore(t, store)
+}
+
+// TestFileBookingStore validates storing bookings in a file.
+func TestFileBookingStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir, err := ioutil.TempDir("", "bookings")
+	if err != nil {
+		t.Fatalf("cannot create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bookings.json")
+	store, err := services.StartFileBookingStore(ctx, path)
+	if err != nil {
+		t.Fatalf("starting store failed: %v", err)
+	}
+
+	testBookingStore(t, store)
+
+	// Read again by new store.
+	store, err = services.StartFileBookingStore(ctx, path)
+	if err != nil {
+		t.Fatalf("restarting store failed: %v", err)
+	}
+	all, err := store.All()
+	if err != nil {
+		t.Fatalf("reading all bookings failed: %v", err)
+	}
+	if len(all) != 1 || len(all["bar"]) != 1 {
+		t.Fatalf("invalid restored bookings: %v", all)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("invalid"), 0644); err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	if _, err = services.StartFileBookingStore(ctx, path); err == nil {
+		t.Fatalf("starting store with invalid file did not fail")
+	}
+}
+
+// TestProviderRestore validates persisting bookings by a Provider
+// and restoring them by a new one.
+func TestProviderRestore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := services.StartInMemoryBookingStore(ctx)
+	c := newTestCatalog(t)
+	p := services.StartProvider(ctx, services.WithBookingStore(store), services.WithCatalog(c))
+
+	svcCnt, err := p.BookSpecs("foo",
+		services.Spec{ServiceID: "a", Type: "echo"},
+		services.Spec{ServiceID: "b", Type: "echo", Config: json.RawMessage(`{"fail":true}`)},
+		services.Spec{ServiceID: "c", Type: "echo"},
+	)
+	if err != nil {
+		t.Fatalf("booking specs failed: %v", err)
+	}
+	if svcCnt != 3 {
+		t.Fatalf("invalid number of services, expect 3: %d", svcCnt)
+	}
+	if _, err = p.BookSpecs("foo", services.Spec{ServiceID: "d", Type: "unknown"}); err == nil {
+		t.Fatalf("booking spec with unknown type did not fail")
+	}
+	if _, err = p.Unbook("foo", "c"); err != nil {
+		t.Fatalf("unbooking failed: %v", err)
+	}
+	if _, err = p.BookSpecs("bar", services.Spec{ServiceID: "a", Type: "echo"}); err != nil {
+		t.Fatalf("booking specs failed: %v", err)
+	}
+	if _, err = p.Unbook("bar", "a"); err != nil {
+		t.Fatalf("unbooking failed: %v", err)
+	}
+	// Plain bookings replace persisted ones.
+	if _, err = p.Book("foo", echoService{id: "a"}); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+
+	all, err := store.All()
+	if err != nil {
+		t.Fatalf("reading all bookings failed: %v", err)
+	}
+	if len(all) != 1 || len(all["foo"]) != 1 || all["foo"][0].ServiceID != "b" {
+		t.Fatalf("invalid persisted bookings: %v", all)
+	}
+
+	// Restore in new Provider.
+	p = services.StartProvider(ctx, services.WithBookingStore(store), services.WithCatalog(c))
+	if err := p.Restore(); err != nil {
+		t.Fatalf("restoring failed: %v", err)
+	}
+	results := mustSpawn(t, p, "foo").Wait()
+	if len(results) != 1 || results[0].ServiceID != "b" || results[0].Err == nil {
+		t.Fatalf("invalid results of restored bookings: %v", results)
+	}
+
+	p = services.StartProvider(ctx)
+	if err := p.Restore(); err == nil {
+		t.Fatalf("restoring without store did not fail")
+	}
+}
+
+// TestProviderPersistFailure validates that bookings stay unchanged
+// if persisting them fails.
+func TestProviderPersistFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &failingBookingStore{BookingStore: services.StartInMemoryBookingStore(ctx)}
+	p := services.StartProvider(ctx, services.WithBookingStore(store), services.WithCatalog(newTestCatalog(t)))
+
+	if _, err := p.BookSpecs("foo", services.Spec{ServiceID: "a", Type: "echo"}); err != nil {
+		t.Fatalf("booking specs failed: %v", err)
+	}
+	store.setFailing(true)
+	if _, err := p.BookSpecs("foo", services.Spec{ServiceID: "b", Type: "echo"}); err == nil {
+		t.Fatalf("booking specs with failing store did not fail")
+	}
+	if _, err := p.Book("foo", echoService{id: "a"}); err == nil {
+		t.Fatalf("replacing persisted booking with failing store did not fail")
+	}
+	if _, err := p.Unbook("foo", "a"); err == nil {
+		t.Fatalf("unbooking with failing store did not fail")
+	}
+	if booked := p.Booked("foo"); !reflect.DeepEqual(booked, []string{"a"}) {
+		t.Fatalf("invalid bookings after failures: %v", booked)
+	}
+	if info, ok := p.Booking("foo", "a"); !ok || !info.Persisted {
+		t.Fatalf("invalid booking after failures: %+v", info)
+	}
+
+	store.setFailing(false)
+	if _, err := p.Unbook("foo", "a"); err != nil {
+		t.Fatalf("unbooking failed: %v", err)
+	}
+	all, err := store.All()
+	if err != nil {
+		t.Fatalf("reading all bookings failed: %v", err)
+	}
+	if len(all) != 0 {
+		t.Fatalf("invalid persisted bookings: %v", all)
+	}
+}
+
+// testBookingStore runs the tests common for all BookingStores.
+func testBookingStore(t *testing.T, store services.BookingStore) {
+	fooSpecs := []services.Spec{
+		{ServiceID: "a", Type: "echo"},
+		{ServiceID: "b", Type: "echo", Config: json.RawMessage(`{"fail":true}`)},
+	}
+	barSpecs := []services.Spec{
+		{ServiceID: "c", Type: "echo"},
+	}
+	if err := store.Put("foo", fooSpecs); err != nil {
+		t.Fatalf("putting foo failed: %v", err)
+	}
+	if err := store.Put("bar", barSpecs); err != nil {
+		t.Fatalf("putting bar failed: %v", err)
+	}
+	all, err := store.All()
+	if err != nil {
+		t.Fatalf("reading all bookings failed: %v", err)
+	}
+	if !reflect.DeepEqual(all, map[string][]services.Spec{"foo": fooSpecs, "bar": barSpecs}) {
+		t.Fatalf("invalid bookings: %v", all)
+	}
+	if err := store.Delete("foo"); err != nil {
+		t.Fatalf("deleting foo failed: %v", err)
+	}
+	all, err = store.All()
+	if err != nil {
+		t.Fatalf("reading all bookings failed: %v", err)
+	}
+	if _, ok := all["foo"]; ok || len(all) != 1 {
+		t.Fatalf("invalid bookings after delete: %v", all)
+	}
+}
+
+// failingBookingStore lets the writing to the embedded BookingStore
+// fail on demand.
+type failingBookingStore struct {
+	services.BookingStore
+	mu      sync.Mutex
+	failing bool
+}
+
+func (s *failingBookingStore) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *failingBookingStore) fail() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return errors.New("store failed")
+	}
+	return nil
+}
+
+func (s *failingBookingStore) Put(consumerID string, specs []services.Spec) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.BookingStore.Put(consumerID, specs)
+}
+
+func (s *failingBookingStore) Delete(consumerID string) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.BookingStore.Delete(consumerID)
+}
@@ -0,0 +1,207 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of executing a Service while
+// its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets all executions pass.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen rejects all executions.
+	BreakerOpen
+
+	// BreakerHalfOpen lets one trial execution pass.
+	BreakerHalfOpen
+)
+
+// String implements fmt.Stringer.
+func (bs BreakerState) String() string {
+	switch bs {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig configures a CircuitBreaker.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures
+	// opening the breaker. Default is 5.
+	FailureThreshold int
+
+	// SuccessThreshold is the number of consecutive successful
+	// trials closing the half-open breaker again. Default is 1.
+	SuccessThreshold int
+
+	// CoolDown is the duration the breaker stays open before
+	// switching to half-open. Default is 30 seconds.
+	CoolDown time.Duration
+}
+
+// BreakerStatus describes the current status of a CircuitBreaker.
+type BreakerStatus struct {
+	State     BreakerState
+	Failures  int
+	Successes int
+	OpenedAt  time.Time
+}
+
+// CircuitBreaker protects the backends of failing services. After a
+// number of consecutive failures it opens and rejects executions for
+// a cool-down period. Afterwards trial executions decide if it closes
+// again or stays open.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	cfg       BreakerConfig
+	state     BreakerState
+	failures  int
+	successes int
+	openedAt  time.Time
+	trialing  bool
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker.
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.SuccessThreshold < 1 {
+		cfg.SuccessThreshold = 1
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = 30 * time.Second
+	}
+	return &CircuitBreaker{
+		cfg: cfg,
+	}
+}
+
+// Allow checks if an execution may pass. In that case its outcome
+// has to be reported. Otherwise ErrCircuitOpen is returned.
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.update()
+	switch cb.state {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if cb.trialing {
+			return ErrCircuitOpen
+		}
+		cb.trialing = true
+	}
+	return nil
+}
+
+// Report passes the outcome of an allowed execution to the breaker.
+// Cancelled executions are not counted.
+func (cb *CircuitBreaker) Report(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trialing = false
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err != nil:
+		cb.successes = 0
+		cb.failures++
+		if cb.state == BreakerHalfOpen || cb.failures >= cb.cfg.FailureThreshold {
+			cb.state = BreakerOpen
+			cb.openedAt = time.Now()
+		}
+	default:
+		cb.failures = 0
+		if cb.state == BreakerHalfOpen {
+			cb.successes++
+			if cb.successes >= cb.cfg.SuccessThreshold {
+				cb.state = BreakerClosed
+				cb.successes = 0
+			}
+		}
+	}
+}
+
+// Status returns the current status of the breaker.
+func (cb *CircuitBreaker) Status() BreakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.update()
+	return BreakerStatus{
+		State:     cb.state,
+		Failures:  cb.failures,
+		Successes: cb.successes,
+		OpenedAt:  cb.openedAt,
+	}
+}
+
+// Reset closes the breaker and clears its counters.
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state = BreakerClosed
+	cb.failures = 0
+	cb.successes = 0
+	cb.openedAt = time.Time{}
+	cb.trialing = false
+}
+
+// update switches an open breaker to half-open after the cool-down.
+func (cb *CircuitBreaker) update() {
+	if cb.state == BreakerOpen && time.Since(cb.openedAt) >= cb.cfg.CoolDown {
+		cb.state = BreakerHalfOpen
+		cb.successes = 0
+	}
+}
+
+// breakerService decorates a Service with a CircuitBreaker.
+type breakerService struct {
+	wrapper
+	breaker *CircuitBreaker
+}
+
+// WithCircuitBreaker decorates a Service with a CircuitBreaker. The
+// same breaker may be used for multiple services sharing a backend.
+func WithCircuitBreaker(svc Service, cb *CircuitBreaker) Service {
+	return &breakerService{
+		wrapper: wrapper{svc},
+		breaker: cb,
+	}
+}
+
+// Do implements Service.
+func (bs *breakerService) Do() error {
+	return bs.DoContext(context.Background())
+}
+
+// DoContext implements ContextService.
+func (bs *breakerService) DoContext(ctx context.Context) error {
+	if err := bs.breaker.Allow(); err != nil {
+		return err
+	}
+	err := do(ctx, bs.svc)
+	bs.breaker.Report(err)
+	return err
+}
@@ -0,0 +1,134 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestCircuitBreakerStates validates the state transitions of
+// a CircuitBreaker.
+func TestCircuitBreakerStates(t *testing.T) {
+	cb := services.NewCircuitBreaker(services.BreakerConfig{
+		FailureThreshold: 2,
+		SuccessThreshold: 2,
+		CoolDown:         20 * time.Millisecond,
+	})
+	errOuch := errors.New("ouch")
+	state := func(expected services.BreakerState) {
+		t.Helper()
+		if status := cb.Status(); status.State != expected {
+			t.Fatalf("breaker is %v, expect %v", status.State, expected)
+		}
+	}
+	pass := func() {
+		t.Helper()
+		if err := cb.Allow(); err != nil {
+			t.Fatalf("breaker does not allow execution: %v", err)
+		}
+	}
+	reject := func() {
+		t.Helper()
+		if err := cb.Allow(); err != services.ErrCircuitOpen {
+			t.Fatalf("breaker does not reject execution: %v", err)
+		}
+	}
+
+	// Closed until two consecutive failures.
+	pass()
+	cb.Report(errOuch)
+	pass()
+	cb.Report(nil)
+	pass()
+	cb.Report(errOuch)
+	state(services.BreakerClosed)
+	pass()
+	cb.Report(errOuch)
+	state(services.BreakerOpen)
+	reject()
+
+	// Half-open after cool-down with only one trial, failing
+	// trial opens again.
+	time.Sleep(30 * time.Millisecond)
+	state(services.BreakerHalfOpen)
+	pass()
+	reject()
+	cb.Report(errOuch)
+	state(services.BreakerOpen)
+
+	// Two successful trials close it.
+	time.Sleep(30 * time.Millisecond)
+	pass()
+	cb.Report(nil)
+	state(services.BreakerHalfOpen)
+	pass()
+	cb.Report(nil)
+	state(services.BreakerClosed)
+
+	// Reset.
+	pass()
+	cb.Report(errOuch)
+	pass()
+	cb.Report(errOuch)
+	state(services.BreakerOpen)
+	cb.Reset()
+	state(services.BreakerClosed)
+}
+
+// TestProviderCircuitBreakers validates circuit breakers applied
+// by the Provider per service ID.
+func TestProviderCircuitBreakers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithCircuitBreakers(services.BreakerConfig{
+		FailureThreshold: 2,
+		CoolDown:         time.Minute,
+	}, "a"))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	calls := 0
+	failing := func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return errors.New("ouch")
+	}
+	p.Book("foo", newCtxService("a", failing, &wg))
+	p.Book("bar", newCtxService("a", failing, &wg))
+
+	wg.Add(2)
+	mustSpawn(t, p, "foo").Wait()
+	mustSpawn(t, p, "bar").Wait()
+	s := mustSpawn(t, p, "foo")
+	s.Wait()
+
+	if result, _ := s.Result("a"); result.Err != services.ErrCircuitOpen {
+		t.Fatalf("a has not been rejected: %v", result.Err)
+	}
+	if calls != 2 {
+		t.Fatalf("a has been called %d times, expect 2", calls)
+	}
+	statuses := p.Breakers()
+	if len(statuses) != 1 || statuses["a"].State != services.BreakerOpen {
+		t.Fatalf("invalid breaker statuses: %v", statuses)
+	}
+	cb, ok := p.Breaker("a")
+	if !ok {
+		t.Fatalf("breaker of a not found")
+	}
+	cb.Reset()
+	if statuses = p.Breakers(); statuses["a"].State != services.BreakerClosed {
+		t.Fatalf("breaker of a has not been reset: %v", statuses["a"])
+	}
+}
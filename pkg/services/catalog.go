@@ -0,0 +1,174 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Spec describes a Service by its ID, type, and configuration, so
+// that it can be created by a Catalog.
+type Spec struct {
+	ServiceID string          `json:"service_id"`
+	Type      string          `json:"type"`
+	Config    json.RawMessage `json:"config,omitempty"`
+}
+
+// NewSpec creates a Spec with the configuration marshalled to JSON.
+func NewSpec(svcID, typ string, config interface{}) (Spec, error) {
+	spec := Spec{
+		ServiceID: svcID,
+		Type:      typ,
+	}
+	if config != nil {
+		data, err := json.Marshal(config)
+		if err != nil {
+			return Spec{}, fmt.Errorf("cannot marshal config of service %q: %v", svcID, err)
+		}
+		spec.Config = data
+	}
+	return spec, nil
+}
+
+// Factory creates a Service with the given ID based on its raw
+// configuration.
+type Factory func(id string, config json.RawMessage) (Service, error)
+
+// ServiceType describes a type of services which can be created
+// with a typed configuration.
+type ServiceType struct {
+	// Name identifies the type inside a Catalog.
+	Name string
+
+	// Description tells what services of this type do.
+	Description string
+
+	// NewConfig returns a pointer to a new configuration with
+	// default values. The JSON configuration of a Spec is
+	// unmarshalled into it, unknown fields are rejected. If nil
+	// the type has no configuration.
+	NewConfig func() interface{}
+
+	// Create creates the Service with the given ID and the
+	// configuration returned by NewConfig.
+	Create func(id string, config interface{}) (Service, error)
+}
+
+// Catalog contains the types of services, so that they can be
+// created by their Specs.
+type Catalog struct {
+	mu    sync.RWMutex
+	types map[string]ServiceType
+}
+
+// DefaultCatalog is the Catalog used by Providers if no other one
+// is configured.
+var DefaultCatalog = NewCatalog()
+
+// Register adds a ServiceType to the DefaultCatalog.
+func Register(st ServiceType) error {
+	return DefaultCatalog.Register(st)
+}
+
+// NewCatalog creates an empty Catalog.
+func NewCatalog() *Catalog {
+	return &Catalog{
+		types: make(map[string]ServiceType),
+	}
+}
+
+// Register adds a ServiceType to the Catalog.
+func (c *Catalog) Register(st ServiceType) error {
+	if st.Name == "" || st.Create == nil {
+		return fmt.Errorf("service type %q needs name and create function", st.Name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.types[st.Name]; ok {
+		return fmt.Errorf("service type %q already registered", st.Name)
+	}
+	c.types[st.Name] = st
+	return nil
+}
+
+// RegisterFactory adds a type of services created by a Factory
+// handling the raw configuration itself.
+func (c *Catalog) RegisterFactory(name string, factory Factory) error {
+	return c.Register(ServiceType{
+		Name: name,
+		NewConfig: func() interface{} {
+			return &json.RawMessage{}
+		},
+		Create: func(id string, config interface{}) (Service, error) {
+			return factory(id, *config.(*json.RawMessage))
+		},
+	})
+}
+
+// Type returns the ServiceType with the given name.
+func (c *Catalog) Type(name string) (ServiceType, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	st, ok := c.types[name]
+	return st, ok
+}
+
+// Types returns all ServiceTypes ordered by name.
+func (c *Catalog) Types() []ServiceType {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	sts := make([]ServiceType, 0, len(c.types))
+	for _, st := range c.types {
+		sts = append(sts, st)
+	}
+	sort.Slice(sts, func(i, j int) bool {
+		return sts[i].Name < sts[j].Name
+	})
+	return sts
+}
+
+// Create creates a Service based on its Spec.
+func (c *Catalog) Create(spec Spec) (Service, error) {
+	st, ok := c.Type(spec.Type)
+	if !ok {
+		return nil, fmt.Errorf("service type %q not registered", spec.Type)
+	}
+	var config interface{}
+	if st.NewConfig != nil {
+		config = st.NewConfig()
+		if len(spec.Config) > 0 {
+			if err := decodeConfig(spec.Config, config); err != nil {
+				return nil, fmt.Errorf("invalid config of service %q of type %q: %v", spec.ServiceID, spec.Type, err)
+			}
+		}
+	} else if len(spec.Config) > 0 && string(spec.Config) != "null" {
+		return nil, fmt.Errorf("service type %q has no config", spec.Type)
+	}
+	svc, err := st.Create(spec.ServiceID, config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create service %q of type %q: %v", spec.ServiceID, spec.Type, err)
+	}
+	return svc, nil
+}
+
+// decodeConfig unmarshals the raw configuration into the typed one.
+// Raw messages are simply copied, otherwise unknown fields are
+// rejected.
+func decodeConfig(raw json.RawMessage, config interface{}) error {
+	if rm, ok := config.(*json.RawMessage); ok {
+		*rm = append((*rm)[:0], raw...)
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(config)
+}
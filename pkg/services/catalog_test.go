@@ -0,0 +1,178 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestCatalogFactory validates the registration of factories and
+// the creation of services by Specs.
+func TestCatalogFactory(t *testing.T) {
+	c := newTestCatalog(t)
+
+	if err := c.RegisterFactory("echo", echoFactory); err == nil {
+		t.Fatalf("registering echo twice did not fail")
+	}
+	svc, err := c.Create(services.Spec{ServiceID: "a", Type: "echo"})
+	if err != nil {
+		t.Fatalf("creating a failed: %v", err)
+	}
+	if svc.ID() != "a" {
+		t.Fatalf("a has wrong ID: %q", svc.ID())
+	}
+	if _, err := c.Create(services.Spec{ServiceID: "b", Type: "unknown"}); err == nil {
+		t.Fatalf("creating b with unknown type did not fail")
+	}
+	if _, err := c.Create(services.Spec{ServiceID: "c", Type: "echo", Config: json.RawMessage("invalid")}); err == nil {
+		t.Fatalf("creating c with invalid config did not fail")
+	}
+}
+
+// TestCatalogTypes validates service types with typed
+// configurations.
+func TestCatalogTypes(t *testing.T) {
+	c := newTestCatalog(t)
+	type greeterConfig struct {
+		Greeting string `json:"greeting"`
+		Times    int    `json:"times"`
+	}
+	var created greeterConfig
+	err := c.Register(services.ServiceType{
+		Name:        "greeter",
+		Description: "Greets multiple times.",
+		NewConfig: func() interface{} {
+			return &greeterConfig{Greeting: "hello", Times: 1}
+		},
+		Create: func(id string, config interface{}) (services.Service, error) {
+			created = *config.(*greeterConfig)
+			if created.Times < 1 {
+				return nil, errors.New("times must be positive")
+			}
+			return echoService{id: id}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("registering greeter failed: %v", err)
+	}
+	err = c.Register(services.ServiceType{
+		Name: "noop",
+		Create: func(id string, config interface{}) (services.Service, error) {
+			return echoService{id: id}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("registering noop failed: %v", err)
+	}
+	if err := c.Register(services.ServiceType{Name: "invalid"}); err == nil {
+		t.Fatalf("registering type without create function did not fail")
+	}
+
+	types := c.Types()
+	if len(types) != 3 || types[0].Name != "echo" || types[1].Name != "greeter" || types[2].Name != "noop" {
+		t.Fatalf("invalid types: %v", types)
+	}
+	if st, ok := c.Type("greeter"); !ok || st.Description != "Greets multiple times." {
+		t.Fatalf("invalid greeter type: %v", st)
+	}
+
+	spec, err := services.NewSpec("a", "greeter", map[string]int{"times": 3})
+	if err != nil {
+		t.Fatalf("creating spec failed: %v", err)
+	}
+	if _, err := c.Create(spec); err != nil {
+		t.Fatalf("creating a failed: %v", err)
+	}
+	if created.Greeting != "hello" || created.Times != 3 {
+		t.Fatalf("invalid config: %v", created)
+	}
+	invalids := []services.Spec{
+		{ServiceID: "b", Type: "greeter", Config: json.RawMessage(`{"times":0}`)},
+		{ServiceID: "c", Type: "greeter", Config: json.RawMessage(`{"unknown":true}`)},
+		{ServiceID: "d", Type: "greeter", Config: json.RawMessage(`{"times":"many"}`)},
+		{ServiceID: "e", Type: "noop", Config: json.RawMessage(`{"times":1}`)},
+	}
+	for _, invalid := range invalids {
+		if _, err := c.Create(invalid); err == nil {
+			t.Fatalf("creating %s with invalid config did not fail", invalid.ServiceID)
+		}
+	}
+	if _, err := c.Create(services.Spec{ServiceID: "f", Type: "noop"}); err != nil {
+		t.Fatalf("creating f failed: %v", err)
+	}
+}
+
+// TestProviderBookSpecs validates booking services by type and
+// configuration via a Provider.
+func TestProviderBookSpecs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithCatalog(newTestCatalog(t)))
+
+	a, _ := services.NewSpec("a", "echo", nil)
+	b, _ := services.NewSpec("b", "echo", map[string]bool{"fail": true})
+	svcCnt, err := p.BookSpecs("foo", a, b)
+	if err != nil {
+		t.Fatalf("booking specs failed: %v", err)
+	}
+	if svcCnt != 2 {
+		t.Fatalf("invalid number of services, expect 2: %d", svcCnt)
+	}
+	s := mustSpawn(t, p, "foo")
+	s.Wait()
+	if result, _ := s.Result("a"); result.Err != nil {
+		t.Fatalf("a failed: %v", result.Err)
+	}
+	if result, _ := s.Result("b"); result.Err == nil {
+		t.Fatalf("b did not fail")
+	}
+}
+
+// -----
+// echoService is a simple Service created by a
+// Factory for testing purposes.
+// -----
+
+type echoService struct {
+	id   string
+	Fail bool `json:"fail"`
+}
+
+func echoFactory(id string, config json.RawMessage) (services.Service, error) {
+	svc := echoService{id: id}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &svc); err != nil {
+			return nil, err
+		}
+	}
+	return svc, nil
+}
+
+func newTestCatalog(t *testing.T) *services.Catalog {
+	c := services.NewCatalog()
+	if err := c.RegisterFactory("echo", echoFactory); err != nil {
+		t.Fatalf("registering echo failed: %v", err)
+	}
+	return c
+}
+
+func (s echoService) ID() string {
+	return s.id
+}
+
+func (s echoService) Do() error {
+	if s.Fail {
+		return errors.New("echo failed")
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField contains the allowed values of one field of a
+// cron expression.
+type cronField map[int]bool
+
+// cronBounds are the minimal and maximal values of the fields
+// minute, hour, day of month, month, and day of week.
+var cronBounds = [5][2]int{
+	{0, 59},
+	{0, 23},
+	{1, 31},
+	{1, 12},
+	{0, 6},
+}
+
+// cronExpr is a parsed cron expression.
+type cronExpr struct {
+	minutes     cronField
+	hours       cronField
+	daysOfMonth cronField
+	months      cronField
+	daysOfWeek  cronField
+	anyDOM      bool
+	anyDOW      bool
+	location    *time.Location
+}
+
+// parseCron parses a cron expression with the five fields minute,
+// hour, day of month, month, and day of week. Each field may be a
+// "*", a value, a range like "1-5", or a comma separated list of
+// them. Ranges and "*" may have a step like "*/15". The expression
+// is evaluated in the given location.
+func parseCron(expr string, location *time.Location) (*cronExpr, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron expression %q needs 5 fields, has %d", expr, len(parts))
+	}
+	var fields [5]cronField
+	for i, part := range parts {
+		field, err := parseCronField(part, cronBounds[i][0], cronBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+		}
+		fields[i] = field
+	}
+	// Sunday may also be written as 7.
+	if fields[4][7] {
+		delete(fields[4], 7)
+		fields[4][0] = true
+	}
+	if location == nil {
+		location = time.Local
+	}
+	return &cronExpr{
+		minutes:     fields[0],
+		hours:       fields[1],
+		daysOfMonth: fields[2],
+		months:      fields[3],
+		daysOfWeek:  fields[4],
+		anyDOM:      strings.HasPrefix(parts[2], "*"),
+		anyDOW:      strings.HasPrefix(parts[4], "*"),
+		location:    location,
+	}, nil
+}
+
+// parseCronField parses one field of a cron expression.
+func parseCronField(part string, min, max int) (cronField, error) {
+	field := cronField{}
+	if min == 0 && max == 6 {
+		// Allow 7 for Sunday in day of week.
+		max = 7
+	}
+	for _, item := range strings.Split(part, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			s, err := strconv.Atoi(item[i+1:])
+			if err != nil || s < 1 {
+				return nil, fmt.Errorf("invalid step in %q", item)
+			}
+			rng, step = item[:i], s
+		}
+		first, last := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			f, ferr := strconv.Atoi(bounds[0])
+			l, lerr := strconv.Atoi(bounds[1])
+			if ferr != nil || lerr != nil {
+				return nil, fmt.Errorf("invalid range %q", rng)
+			}
+			first, last = f, l
+		default:
+			v, err := strconv.Atoi(rng)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", rng)
+			}
+			first, last = v, v
+			if step > 1 {
+				last = max
+			}
+		}
+		if first < min || last > max || first > last {
+			return nil, fmt.Errorf("%q is out of range %d-%d", item, min, max)
+		}
+		for v := first; v <= last; v += step {
+			field[v] = true
+		}
+	}
+	return field, nil
+}
+
+// next returns the first time matching the expression after the
+// given time. A zero time is returned if there is none within the
+// next five years.
+func (ce *cronExpr) next(after time.Time) time.Time {
+	t := after.In(ce.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		if !ce.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, ce.location)
+			continue
+		}
+		if !ce.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, ce.location)
+			continue
+		}
+		if !ce.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, ce.location)
+			continue
+		}
+		if !ce.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchesDay checks day of month and day of week. Like in the
+// classic cron one of both has to match if both are restricted.
+func (ce *cronExpr) matchesDay(t time.Time) bool {
+	dom := ce.daysOfMonth[t.Day()]
+	dow := ce.daysOfWeek[int(t.Weekday())]
+	switch {
+	case ce.anyDOM && ce.anyDOW:
+		return true
+	case ce.anyDOM:
+		return dow
+	case ce.anyDOW:
+		return dom
+	default:
+		return dom || dow
+	}
+}
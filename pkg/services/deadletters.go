@@ -0,0 +1,314 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownDeadLetter is returned for IDs of unknown dead letters.
+var ErrUnknownDeadLetter = errors.New("unknown dead letter")
+
+// DeadLetter describes a failed service execution.
+type DeadLetter struct {
+	ID         string
+	ConsumerID string
+	ServiceID  string
+	Err        string
+	Failed     time.Time
+	Attempts   int
+}
+
+// DeadLetterQueue captures the failed service executions of a
+// Provider. Executions rejected before running or cancelled ones
+// are not captured. It is safe for concurrent usage.
+type DeadLetterQueue struct {
+	mu       sync.Mutex
+	capacity int
+	seq      uint64
+	letters  []DeadLetter
+}
+
+// NewDeadLetterQueue creates a DeadLetterQueue keeping at most
+// capacity dead letters, dropping the oldest ones. Zero means no
+// limit.
+func NewDeadLetterQueue(capacity int) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		capacity: capacity,
+	}
+}
+
+// Letters returns the dead letters of the consumer, all if the ID
+// is empty, oldest first.
+func (dlq *DeadLetterQueue) Letters(consumerID string) []DeadLetter {
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	var letters []DeadLetter
+	for _, dl := range dlq.letters {
+		if consumerID == "" || dl.ConsumerID == consumerID {
+			letters = append(letters, dl)
+		}
+	}
+	return letters
+}
+
+// Letter returns the dead letter with the given ID.
+func (dlq *DeadLetterQueue) Letter(id string) (DeadLetter, bool) {
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	for _, dl := range dlq.letters {
+		if dl.ID == id {
+			return dl, true
+		}
+	}
+	return DeadLetter{}, false
+}
+
+// Len returns the number of dead letters.
+func (dlq *DeadLetterQueue) Len() int {
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	return len(dlq.letters)
+}
+
+// Purge removes the dead letters with the given IDs, all if none
+// are passed. The number of removed dead letters is returned.
+func (dlq *DeadLetterQueue) Purge(ids ...string) int {
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	if len(ids) == 0 {
+		n := len(dlq.letters)
+		dlq.letters = nil
+		return n
+	}
+	return dlq.remove(ids...)
+}
+
+// capture adds the Result of a failed execution.
+func (dlq *DeadLetterQueue) capture(consumerID string, result Result) {
+	if result.Err == nil || rejected(result.Err) || errors.Is(result.Err, context.Canceled) {
+		return
+	}
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	dlq.seq++
+	dlq.letters = append(dlq.letters, DeadLetter{
+		ID:         fmt.Sprintf("dl-%d", dlq.seq),
+		ConsumerID: consumerID,
+		ServiceID:  result.ServiceID,
+		Err:        result.Err.Error(),
+		Failed:     result.Started.Add(result.Duration),
+		Attempts:   result.Attempts,
+	})
+	if dlq.capacity > 0 && len(dlq.letters) > dlq.capacity {
+		dlq.letters = dlq.letters[len(dlq.letters)-dlq.capacity:]
+	}
+}
+
+// remove removes the dead letters with the given IDs without
+// locking.
+func (dlq *DeadLetterQueue) remove(ids ...string) int {
+	drop := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+	letters := dlq.letters[:0]
+	for _, dl := range dlq.letters {
+		if !drop[dl.ID] {
+			letters = append(letters, dl)
+		}
+	}
+	n := len(dlq.letters) - len(letters)
+	dlq.letters = letters
+	return n
+}
+
+// take removes and returns the dead letters with the given IDs,
+// or the ones of the consumer, all if the ID is empty.
+func (dlq *DeadLetterQueue) take(consumerID string, ids ...string) ([]DeadLetter, error) {
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	var letters []DeadLetter
+	if len(ids) > 0 {
+		for _, id := range ids {
+			found := false
+			for _, dl := range dlq.letters {
+				if dl.ID == id {
+					letters = append(letters, dl)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("cannot take dead letter %q: %w", id, ErrUnknownDeadLetter)
+			}
+		}
+	} else {
+		for _, dl := range dlq.letters {
+			if consumerID == "" || dl.ConsumerID == consumerID {
+				letters = append(letters, dl)
+				ids = append(ids, dl.ID)
+			}
+		}
+	}
+	dlq.remove(ids...)
+	return letters, nil
+}
+
+// restore adds taken dead letters again.
+func (dlq *DeadLetterQueue) restore(letters []DeadLetter) {
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	dlq.letters = append(dlq.letters, letters...)
+	sort.SliceStable(dlq.letters, func(i, j int) bool {
+		return dlq.letters[i].Failed.Before(dlq.letters[j].Failed)
+	})
+}
+
+// WithDeadLetterQueue captures failed service executions in the
+// DeadLetterQueue.
+func WithDeadLetterQueue(dlq *DeadLetterQueue) Option {
+	return func(p *Provider) {
+		p.deadLetters = dlq
+	}
+}
+
+// SkippedLetter describes a dead letter which could not be replayed.
+type SkippedLetter struct {
+	Letter DeadLetter
+	Err    error
+}
+
+// ReplayError aggregates the dead letters skipped by a replay. They
+// are kept in the DeadLetterQueue.
+type ReplayError struct {
+	Skipped []SkippedLetter
+}
+
+// Error implements the error interface.
+func (e *ReplayError) Error() string {
+	msgs := make([]string, len(e.Skipped))
+	for i, sl := range e.Skipped {
+		msgs[i] = fmt.Sprintf("dead letter %q: %v", sl.Letter.ID, sl.Err)
+	}
+	return fmt.Sprintf("replay of %d dead letter(s) skipped: %s", len(e.Skipped), strings.Join(msgs, "; "))
+}
+
+// Errors returns the individual errors of the skipped dead letters.
+func (e *ReplayError) Errors() []error {
+	errs := make([]error, len(e.Skipped))
+	for i, sl := range e.Skipped {
+		errs[i] = sl.Err
+	}
+	return errs
+}
+
+// Replay executes the services of the dead letters with the given
+// IDs again. They are taken out of the DeadLetterQueue, failing
+// executions are captured as new dead letters. The services are
+// spawned per consumer with their current bookings, so the returned
+// spawnings are ordered by consumer ID. Dead letters of no longer
+// booked services or of rejected spawnings are skipped and returned
+// in a *ReplayError, while the others are replayed. Dead letters
+// may belong to any consumer, so with an Authenticator replaying has
+// to be done by a Session for its own dead letters.
+func (p *Provider) Replay(ids ...string) ([]*Spawning, error) {
+	if err := p.requireSession(); err != nil {
+		return nil, fmt.Errorf("cannot replay dead letters: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return p.replay("", ids...)
+}
+
+// ReplayAll executes the services of all dead letters of the consumer
+// again, of all consumers if the ID is empty. See Replay.
+func (p *Provider) ReplayAll(consumerID string) ([]*Spawning, error) {
+	if err := p.requireSession(); err != nil {
+		return nil, fmt.Errorf("cannot replay dead letters: %w", err)
+	}
+	return p.replay(consumerID)
+}
+
+// replay takes the dead letters and spawns their services.
+func (p *Provider) replay(consumerID string, ids ...string) ([]*Spawning, error) {
+	if p.deadLetters == nil {
+		return nil, fmt.Errorf("cannot replay dead letters: no dead letter queue")
+	}
+	letters, err := p.deadLetters.take(consumerID, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot replay dead letters: %w", err)
+	}
+	byConsumer := make(map[string][]DeadLetter)
+	for _, dl := range letters {
+		byConsumer[dl.ConsumerID] = append(byConsumer[dl.ConsumerID], dl)
+	}
+	consumerIDs := make([]string, 0, len(byConsumer))
+	for id := range byConsumer {
+		consumerIDs = append(consumerIDs, id)
+	}
+	sort.Strings(consumerIDs)
+	var spawnings []*Spawning
+	var skipped []SkippedLetter
+	for _, id := range consumerIDs {
+		booked := make(map[string]bool)
+		for _, svcID := range p.Booked(id) {
+			booked[svcID] = true
+		}
+		seen := make(map[string]bool)
+		var svcIDs []string
+		var replayed []DeadLetter
+		for _, dl := range byConsumer[id] {
+			if !booked[dl.ServiceID] {
+				skipped = append(skipped, SkippedLetter{
+					Letter: dl,
+					Err:    fmt.Errorf("service %q is not booked by consumer %q", dl.ServiceID, id),
+				})
+				continue
+			}
+			replayed = append(replayed, dl)
+			if !seen[dl.ServiceID] {
+				seen[dl.ServiceID] = true
+				svcIDs = append(svcIDs, dl.ServiceID)
+			}
+		}
+		if len(svcIDs) == 0 {
+			continue
+		}
+		s, err := p.spawnContext(context.Background(), id, svcIDs)
+		if err != nil {
+			for _, dl := range replayed {
+				skipped = append(skipped, SkippedLetter{
+					Letter: dl,
+					Err:    err,
+				})
+			}
+			continue
+		}
+		spawnings = append(spawnings, s)
+	}
+	if len(skipped) > 0 {
+		// Keep the dead letters not replayed.
+		letters := make([]DeadLetter, len(skipped))
+		for i, sl := range skipped {
+			letters[i] = sl.Letter
+		}
+		p.deadLetters.restore(letters)
+		return spawnings, &ReplayError{
+			Skipped: skipped,
+		}
+	}
+	return spawnings, nil
+}
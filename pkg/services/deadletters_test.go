@@ -0,0 +1,130 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestProviderDeadLetters validates capturing, replaying, and purging
+// dead letters of failed service executions.
+func TestProviderDeadLetters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dlq := services.NewDeadLetterQueue(0)
+	p := services.StartProvider(ctx, services.WithDeadLetterQueue(dlq))
+	if _, err := p.Book("c", echoService{id: "ok"}, &flakyService{id: "flaky"}, echoService{id: "fail", Fail: true}); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+	if _, err := p.Book("d", echoService{id: "fail", Fail: true}); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+	mustSpawn(t, p, "c").Wait()
+	mustSpawn(t, p, "d").Wait()
+
+	if n := dlq.Len(); n != 3 {
+		t.Fatalf("got %d dead letters, expect 3", n)
+	}
+	letters := dlq.Letters("c")
+	if len(letters) != 2 {
+		t.Fatalf("got %d dead letters of c, expect 2", len(letters))
+	}
+	var flakyID string
+	for _, dl := range letters {
+		if dl.ServiceID == "flaky" {
+			flakyID = dl.ID
+			if dl.Err != "flaky failed" || dl.Attempts != 1 || dl.Failed.IsZero() {
+				t.Fatalf("unexpected dead letter: %+v", dl)
+			}
+		}
+	}
+
+	// Replay individually.
+	spawnings, err := p.Replay(flakyID)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if len(spawnings) != 1 {
+		t.Fatalf("got %d spawnings, expect 1", len(spawnings))
+	}
+	results := spawnings[0].Wait()
+	if len(results) != 1 || results[0].ServiceID != "flaky" || results[0].Err != nil {
+		t.Fatalf("unexpected replay results: %v", results)
+	}
+	if _, ok := dlq.Letter(flakyID); ok {
+		t.Fatalf("replayed dead letter is still queued")
+	}
+	if _, err := p.Replay(flakyID); !errors.Is(err, services.ErrUnknownDeadLetter) {
+		t.Fatalf("expected unknown dead letter error, got %v", err)
+	}
+
+	// Replay in bulk, failing again.
+	spawnings, err = p.ReplayAll("c")
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	spawnings[0].Wait()
+	letters = dlq.Letters("c")
+	if len(letters) != 1 || letters[0].ServiceID != "fail" {
+		t.Fatalf("unexpected dead letters after replay: %+v", letters)
+	}
+
+	// Replay of an unbooked service keeps the dead letter, the
+	// others are replayed.
+	if _, err := p.Unbook("d", "fail"); err != nil {
+		t.Fatalf("unbooking failed: %v", err)
+	}
+	spawnings, err = p.ReplayAll("")
+	var rerr *services.ReplayError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected replay error, got %v", err)
+	}
+	if len(rerr.Skipped) != 1 || rerr.Skipped[0].Letter.ConsumerID != "d" || rerr.Skipped[0].Err == nil {
+		t.Fatalf("unexpected skipped dead letters: %+v", rerr.Skipped)
+	}
+	if len(spawnings) != 1 {
+		t.Fatalf("got %d spawnings, expect 1", len(spawnings))
+	}
+	spawnings[0].Wait()
+	if n := len(dlq.Letters("d")); n != 1 {
+		t.Fatalf("got %d dead letters of d, expect 1", n)
+	}
+	letters = dlq.Letters("c")
+	if len(letters) != 1 || letters[0].ServiceID != "fail" {
+		t.Fatalf("unexpected dead letters after replay: %+v", letters)
+	}
+
+	if n := dlq.Purge(letters[0].ID); n != 1 {
+		t.Fatalf("purged %d dead letters, expect 1", n)
+	}
+	if n := dlq.Purge(); n != 1 || dlq.Len() != 0 {
+		t.Fatalf("purged %d dead letters, %d left", n, dlq.Len())
+	}
+}
+
+// flakyService fails only on its first execution.
+type flakyService struct {
+	id    string
+	calls int32
+}
+
+func (s *flakyService) ID() string {
+	return s.id
+}
+
+func (s *flakyService) Do() error {
+	if atomic.AddInt32(&s.calls, 1) == 1 {
+		return errors.New("flaky failed")
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownDependency is wrapped by a DependencyError if a Service
+// depends on a Service not contained in the same Services.
+var ErrUnknownDependency = errors.New("unknown dependency")
+
+// Dependent is implemented by services depending on the successful
+// execution of other services with the returned IDs before they
+// can be executed themselves.
+type Dependent interface {
+	// DependsOn returns the IDs of the services this one
+	// depends on.
+	DependsOn() []string
+}
+
+// dependentService decorates a Service with dependencies.
+type dependentService struct {
+	wrapper
+	svcIDs []string
+}
+
+// WithDependencies decorates a Service with dependencies to other
+// services, e.g. to chain services per booking.
+func WithDependencies(svc Service, svcIDs ...string) Service {
+	return &dependentService{
+		wrapper: wrapper{svc},
+		svcIDs:  svcIDs,
+	}
+}
+
+// DependsOn implements Dependent.
+func (ds *dependentService) DependsOn() []string {
+	return ds.svcIDs
+}
+
+// dependenciesOf returns the dependencies of a Service. They may be
+// declared by the Service itself or one of its decorators.
+func dependenciesOf(svc Service) []string {
+	var svcIDs []string
+	lookup(svc, func(s Service) bool {
+		if d, ok := s.(Dependent); ok {
+			svcIDs = d.DependsOn()
+			return true
+		}
+		return false
+	})
+	return svcIDs
+}
+
+// DependencyError is returned as result of a Service which has been
+// skipped because a Service it depends on failed or is unknown.
+type DependencyError struct {
+	ServiceID    string
+	DependencyID string
+	Err          error
+}
+
+// Error implements the error interface.
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("service %q skipped, dependency %q failed: %v", e.ServiceID, e.DependencyID, e.Err)
+}
+
+// Unwrap returns the error of the dependency.
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
+// CycleError is returned if the dependencies of services contain
+// a cycle.
+type CycleError struct {
+	ServiceIDs []string
+}
+
+// Error implements the error interface.
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle between services %s", strings.Join(e.ServiceIDs, ", "))
+}
+
+// Order returns the IDs of the services in topological order of
+// their dependencies. Each level only depends on services of the
+// former levels, so that the services of one level can be executed
+// concurrently. Unknown dependencies are ignored. In case of a cycle
+// a *CycleError is returned.
+func (svcs Services) Order() ([][]string, error) {
+	pending := make(map[string]int, len(svcs))
+	dependents := make(map[string][]string, len(svcs))
+	for id, svc := range svcs {
+		pending[id] = 0
+		for _, depID := range dependenciesOf(svc) {
+			if _, ok := svcs[depID]; !ok {
+				continue
+			}
+			pending[id]++
+			dependents[depID] = append(dependents[depID], id)
+		}
+	}
+	var levels [][]string
+	var level []string
+	for id, cnt := range pending {
+		if cnt == 0 {
+			level = append(level, id)
+		}
+	}
+	for len(level) > 0 {
+		sort.Strings(level)
+		levels = append(levels, level)
+		var next []string
+		for _, id := range level {
+			delete(pending, id)
+			for _, dependentID := range dependents[id] {
+				pending[dependentID]--
+				if pending[dependentID] == 0 {
+					next = append(next, dependentID)
+				}
+			}
+		}
+		level = next
+	}
+	if len(pending) > 0 {
+		ids := make([]string, 0, len(pending))
+		for id := range pending {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return nil, &CycleError{
+			ServiceIDs: ids,
+		}
+	}
+	return levels, nil
+}
@@ -0,0 +1,113 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestServicesOrder validates the topological ordering of
+// services including the detection of cycles.
+func TestServicesOrder(t *testing.T) {
+	var wg sync.WaitGroup
+	noop := func(ctx context.Context) error { return nil }
+	svcs := services.Services{
+		"fetch-a": newCtxService("fetch-a", noop, &wg),
+		"fetch-b": newCtxService("fetch-b", noop, &wg),
+		"render":  services.WithDependencies(newCtxService("render", noop, &wg), "fetch-a", "fetch-b"),
+		"deliver": services.WithDependencies(newCtxService("deliver", noop, &wg), "render", "unknown"),
+		"log":     services.WithDependencies(newCtxService("log", noop, &wg), "fetch-a"),
+	}
+
+	levels, err := svcs.Order()
+	if err != nil {
+		t.Fatalf("ordering failed: %v", err)
+	}
+	expected := [][]string{
+		{"fetch-a", "fetch-b"},
+		{"log", "render"},
+		{"deliver"},
+	}
+	if !reflect.DeepEqual(levels, expected) {
+		t.Fatalf("invalid order: %v", levels)
+	}
+
+	svcs["fetch-a"] = services.WithDependencies(newCtxService("fetch-a", noop, &wg), "deliver")
+	_, err = svcs.Order()
+	var cerr *services.CycleError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("cycle not detected: %v", err)
+	}
+	if !reflect.DeepEqual(cerr.ServiceIDs, []string{"deliver", "fetch-a", "log", "render"}) {
+		t.Fatalf("cycle contains wrong services: %v", cerr.ServiceIDs)
+	}
+	for _, result := range svcs.Spawn().Wait() {
+		if !errors.As(result.Err, &cerr) {
+			t.Fatalf("%s has no cycle error: %v", result.ServiceID, result.Err)
+		}
+	}
+}
+
+// TestSpawnDependencies validates the execution of services in
+// the order of their dependencies and the skipping of dependents
+// of failed services.
+func TestSpawnDependencies(t *testing.T) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var order []string
+	errOuch := errors.New("ouch")
+	record := func(id string, err error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, id)
+			return err
+		}
+	}
+	svcs := services.Services{
+		"fetch":   newCtxService("fetch", record("fetch", nil), &wg),
+		"render":  services.WithDependencies(newCtxService("render", record("render", nil), &wg), "fetch"),
+		"deliver": services.WithDependencies(newCtxService("deliver", record("deliver", nil), &wg), "render"),
+		"archive": services.WithDependencies(newCtxService("archive", record("archive", errOuch), &wg), "fetch"),
+		"cleanup": services.WithDependencies(newCtxService("cleanup", record("cleanup", nil), &wg), "archive"),
+		"orphan":  services.WithDependencies(newCtxService("orphan", record("orphan", nil), &wg), "unknown"),
+	}
+
+	wg.Add(4)
+	s := svcs.Spawn()
+	s.Wait()
+
+	pos := map[string]int{}
+	for i, id := range order {
+		pos[id] = i
+	}
+	if len(order) != 4 {
+		t.Fatalf("invalid executed services: %v", order)
+	}
+	if !(pos["fetch"] < pos["render"] && pos["render"] < pos["deliver"] && pos["fetch"] < pos["archive"]) {
+		t.Fatalf("invalid execution order: %v", order)
+	}
+	var derr *services.DependencyError
+	result, _ := s.Result("cleanup")
+	if !errors.As(result.Err, &derr) || derr.DependencyID != "archive" || !errors.Is(result.Err, errOuch) {
+		t.Fatalf("cleanup has not been skipped: %v", result.Err)
+	}
+	if result.Attempts != 0 {
+		t.Fatalf("skipped cleanup has attempts: %d", result.Attempts)
+	}
+	result, _ = s.Result("orphan")
+	if !errors.Is(result.Err, services.ErrUnknownDependency) {
+		t.Fatalf("orphan has not been skipped: %v", result.Err)
+	}
+}
@@ -10,5 +10,7 @@
 // unbook. On demand these services can be executed
 // concurrently. The implementor of a service has to
 // take care how needed information is retrieved or
-// passed back.
+// passed back. Types of services can be registered in
+// a Catalog, so that they can be booked by their type
+// name and a JSON configuration.
 package services
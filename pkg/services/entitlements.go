@@ -0,0 +1,153 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/themue/samples/pkg/consumers"
+)
+
+// ErrNotEntitled is wrapped by an EntitlementError.
+var ErrNotEntitled = errors.New("not entitled")
+
+// EntitlementError is returned if a consumer is not entitled to
+// book services by its plan.
+type EntitlementError struct {
+	ConsumerID string
+	Plan       string
+	Reason     string
+}
+
+// Error implements the error interface.
+func (e *EntitlementError) Error() string {
+	if e.Plan == "" {
+		return fmt.Sprintf("consumer %q %v: %s", e.ConsumerID, ErrNotEntitled, e.Reason)
+	}
+	return fmt.Sprintf("consumer %q with plan %q %v: %s", e.ConsumerID, e.Plan, ErrNotEntitled, e.Reason)
+}
+
+// Unwrap returns ErrNotEntitled.
+func (e *EntitlementError) Unwrap() error {
+	return ErrNotEntitled
+}
+
+// Typed can be implemented by services to tell their type. It is
+// used to check the plans of consumers when booking services
+// directly and not by Specs.
+type Typed interface {
+	Type() string
+}
+
+// typeOf returns the type of a service or an empty string.
+func typeOf(svc Service) string {
+	var svcType string
+	lookup(svc, func(s Service) bool {
+		if t, ok := s.(Typed); ok {
+			svcType = t.Type()
+			return true
+		}
+		return false
+	})
+	return svcType
+}
+
+// WithPlans lets the Provider check the plans of the consumers when
+// booking services. The consumers are read by the reader, typically
+// a consumers.Controller. Unknown consumers and consumers without
+// one of the plans cannot book services. Restored bookings are not
+// checked.
+func WithPlans(reader ConsumerReader, plans consumers.Plans) Option {
+	return func(p *Provider) {
+		p.reader = reader
+		p.plans = plans
+	}
+}
+
+// planOf returns the Plan of a consumer. It is nil if the Provider
+// has no plans.
+func (p *Provider) planOf(consumerID string) (*consumers.Plan, error) {
+	if p.plans == nil {
+		return nil, nil
+	}
+	c, err := p.reader.Read(consumerID)
+	if err != nil {
+		return nil, &EntitlementError{
+			ConsumerID: consumerID,
+			Reason:     fmt.Sprintf("cannot read consumer: %v", err),
+		}
+	}
+	return p.planFor(c)
+}
+
+// planFor returns the Plan of a read or authenticated consumer. It
+// is nil if the Provider has no plans.
+func (p *Provider) planFor(c consumers.Consumer) (*consumers.Plan, error) {
+	if p.plans == nil {
+		return nil, nil
+	}
+	plan, ok := p.plans.Of(c)
+	if !ok {
+		return nil, &EntitlementError{
+			ConsumerID: c.ID,
+			Plan:       c.Plan,
+			Reason:     "unknown plan",
+		}
+	}
+	return &plan, nil
+}
+
+// checkTypes checks if the Plan allows the types of the services
+// with the given IDs.
+func checkTypes(consumerID string, plan *consumers.Plan, svcIDs, types []string) error {
+	if plan == nil {
+		return nil
+	}
+	for i, svcType := range types {
+		if plan.Allows(svcType) {
+			continue
+		}
+		reason := fmt.Sprintf("service %q of type %q is not allowed", svcIDs[i], svcType)
+		if svcType == "" {
+			reason = fmt.Sprintf("service %q has no type", svcIDs[i])
+		}
+		return &EntitlementError{
+			ConsumerID: consumerID,
+			Plan:       plan.Name,
+			Reason:     reason,
+		}
+	}
+	return nil
+}
+
+// checkBookings checks if the Plan allows the number of bookings
+// after booking the services with the given IDs. It has to be
+// called in the backend.
+func (p *Provider) checkBookings(consumerID string, plan *consumers.Plan, svcIDs []string) error {
+	if plan == nil || plan.MaxBookings == 0 {
+		return nil
+	}
+	booked := p.bookings[consumerID]
+	svcCnt := len(booked)
+	added := make(map[string]bool)
+	for _, svcID := range svcIDs {
+		if _, ok := booked[svcID]; !ok && !added[svcID] {
+			added[svcID] = true
+			svcCnt++
+		}
+	}
+	if svcCnt > plan.MaxBookings {
+		return &EntitlementError{
+			ConsumerID: consumerID,
+			Plan:       plan.Name,
+			Reason:     fmt.Sprintf("%d bookings exceed the maximum of %d", svcCnt, plan.MaxBookings),
+		}
+	}
+	return nil
+}
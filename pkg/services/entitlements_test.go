@@ -0,0 +1,95 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/themue/samples/pkg/consumers"
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestProviderPlans validates the checking of consumer plans when
+// booking services.
+func TestProviderPlans(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cc := consumers.StartController(ctx, consumers.StartInMemoryStore(ctx))
+	for _, c := range []consumers.Consumer{
+		{ID: "free", Plan: "free"},
+		{ID: "premium", Plan: "premium"},
+		{ID: "gold", Plan: "gold"},
+	} {
+		if err := cc.Add(c); err != nil {
+			t.Fatalf("adding consumer failed: %v", err)
+		}
+	}
+	plans := consumers.NewPlans(
+		consumers.Plan{Name: "free", ServiceTypes: []string{"echo"}, MaxBookings: 2},
+		consumers.Plan{Name: "premium"},
+	)
+	p := services.StartProvider(ctx,
+		services.WithCatalog(newTestCatalog(t)),
+		services.WithPlans(cc, plans),
+	)
+	notEntitled := func(err error, reason string) {
+		t.Helper()
+		if !errors.Is(err, services.ErrNotEntitled) {
+			t.Fatalf("expected entitlement error, got %v", err)
+		}
+		if !strings.Contains(err.Error(), reason) {
+			t.Fatalf("error %q does not contain %q", err, reason)
+		}
+	}
+
+	_, err := p.Book("unknown", typedService{echoService{id: "a"}})
+	notEntitled(err, "cannot read consumer")
+	_, err = p.Book("gold", typedService{echoService{id: "a"}})
+	notEntitled(err, "unknown plan")
+
+	_, err = p.Book("free", echoService{id: "a"})
+	notEntitled(err, `service "a" has no type`)
+	spec, err := services.NewSpec("b", "other", nil)
+	if err != nil {
+		t.Fatalf("creating spec failed: %v", err)
+	}
+	_, err = p.BookSpecs("free", spec)
+	notEntitled(err, `service "b" of type "other" is not allowed`)
+
+	if _, err := p.Book("free", typedService{echoService{id: "a"}}); err != nil {
+		t.Fatalf("booking typed service failed: %v", err)
+	}
+	spec, err = services.NewSpec("b", "echo", nil)
+	if err != nil {
+		t.Fatalf("creating spec failed: %v", err)
+	}
+	if _, err := p.BookSpecs("free", spec); err != nil {
+		t.Fatalf("booking spec failed: %v", err)
+	}
+	_, err = p.Book("free", typedService{echoService{id: "c"}})
+	notEntitled(err, "3 bookings exceed the maximum of 2")
+	if n, err := p.Book("free", typedService{echoService{id: "a"}}); err != nil || n != 2 {
+		t.Fatalf("rebooking returned %d bookings and error %v", n, err)
+	}
+
+	if n, err := p.Book("premium", echoService{id: "a"}, echoService{id: "b"}, echoService{id: "c"}); err != nil || n != 3 {
+		t.Fatalf("premium booking returned %d bookings and error %v", n, err)
+	}
+}
+
+// typedService tells the type of the embedded echoService.
+type typedService struct {
+	echoService
+}
+
+func (s typedService) Type() string {
+	return "echo"
+}
@@ -0,0 +1,169 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventKind defines the kind of an Event.
+type EventKind int
+
+const (
+	// EventBooked signals the booking of a service.
+	EventBooked EventKind = iota + 1
+
+	// EventUnbooked signals the unbooking of a service.
+	EventUnbooked
+
+	// EventSpawnStarted signals the spawning of the booked
+	// services of a consumer.
+	EventSpawnStarted
+
+	// EventServiceSucceeded signals a successful service
+	// execution.
+	EventServiceSucceeded
+
+	// EventServiceFailed signals a failed service execution.
+	EventServiceFailed
+)
+
+// String implements fmt.Stringer.
+func (ek EventKind) String() string {
+	switch ek {
+	case EventBooked:
+		return "booked"
+	case EventUnbooked:
+		return "unbooked"
+	case EventSpawnStarted:
+		return "spawn-started"
+	case EventServiceSucceeded:
+		return "service-succeeded"
+	case EventServiceFailed:
+		return "service-failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes what happened inside a Provider. The ServiceID
+// is empty for EventSpawnStarted. Duration and Err are only set
+// for executed services.
+type Event struct {
+	Kind       EventKind
+	Time       time.Time
+	ConsumerID string
+	ServiceID  string
+	Duration   time.Duration
+	Err        error
+}
+
+// Subscription receives the Events of a Provider. If the receiver
+// is too slow Events are dropped instead of blocking the Provider.
+type Subscription struct {
+	hub     *eventHub
+	kinds   map[EventKind]bool
+	eventC  chan Event
+	dropped uint64
+}
+
+// Events returns the channel to receive the Events. It is closed
+// when unsubscribing.
+func (s *Subscription) Events() <-chan Event {
+	return s.eventC
+}
+
+// Dropped returns the number of Events dropped so far.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe ends the Subscription.
+func (s *Subscription) Unsubscribe() {
+	s.hub.unsubscribe(s)
+}
+
+// eventHub distributes the Events to the Subscriptions.
+type eventHub struct {
+	mu            sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+}
+
+// newEventHub creates a hub without Subscriptions.
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// subscribe adds a Subscription for the given kinds of Events or
+// for all if none are passed.
+func (eh *eventHub) subscribe(size int, kinds ...EventKind) *Subscription {
+	s := &Subscription{
+		hub:    eh,
+		eventC: make(chan Event, size),
+	}
+	if len(kinds) > 0 {
+		s.kinds = make(map[EventKind]bool, len(kinds))
+		for _, kind := range kinds {
+			s.kinds[kind] = true
+		}
+	}
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	eh.subscriptions[s] = struct{}{}
+	return s
+}
+
+// unsubscribe removes the Subscription and closes its channel.
+func (eh *eventHub) unsubscribe(s *Subscription) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	if _, ok := eh.subscriptions[s]; !ok {
+		return
+	}
+	delete(eh.subscriptions, s)
+	close(s.eventC)
+}
+
+// emit passes the Event to all matching Subscriptions without
+// blocking.
+func (eh *eventHub) emit(evt Event) {
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+	for s := range eh.subscriptions {
+		if s.kinds != nil && !s.kinds[evt.Kind] {
+			continue
+		}
+		select {
+		case s.eventC <- evt:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// emitResult emits the Event for the Result of a service execution.
+func (eh *eventHub) emitResult(consumerID string, result Result) {
+	kind := EventServiceSucceeded
+	if result.Err != nil {
+		kind = EventServiceFailed
+	}
+	eh.emit(Event{
+		Kind:       kind,
+		ConsumerID: consumerID,
+		ServiceID:  result.ServiceID,
+		Duration:   result.Duration,
+		Err:        result.Err,
+	})
+}
@@ -0,0 +1,106 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestProviderEvents validates the emitting of events to
+// multiple subscribers.
+func TestProviderEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx)
+	var wg sync.WaitGroup
+	all := p.Subscribe(16)
+	failures := p.Subscribe(16, services.EventServiceFailed)
+	errOuch := errors.New("ouch")
+
+	p.Book("foo",
+		newCtxService("a", func(ctx context.Context) error { return nil }, &wg),
+		newCtxService("b", func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return errOuch
+		}, &wg),
+	)
+	wg.Add(2)
+	mustSpawn(t, p, "foo").Wait()
+	p.Unbook("foo", "a", "unknown")
+
+	kinds := map[services.EventKind]int{}
+	for i := 0; i < 6; i++ {
+		select {
+		case evt := <-all.Events():
+			kinds[evt.Kind]++
+			if evt.ConsumerID != "foo" || evt.Time.IsZero() {
+				t.Fatalf("invalid event: %v", evt)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing events, received: %v", kinds)
+		}
+	}
+	expected := map[services.EventKind]int{
+		services.EventBooked:           2,
+		services.EventSpawnStarted:     1,
+		services.EventServiceSucceeded: 1,
+		services.EventServiceFailed:    1,
+		services.EventUnbooked:         1,
+	}
+	for kind, cnt := range expected {
+		if kinds[kind] != cnt {
+			t.Fatalf("invalid number of %v events: %d", kind, kinds[kind])
+		}
+	}
+
+	evt := <-failures.Events()
+	if evt.Kind != services.EventServiceFailed || evt.ServiceID != "b" || evt.Err != errOuch {
+		t.Fatalf("invalid failure event: %v", evt)
+	}
+	if evt.Duration < 10*time.Millisecond {
+		t.Fatalf("failure event has wrong duration: %v", evt.Duration)
+	}
+	select {
+	case evt := <-failures.Events():
+		t.Fatalf("unexpected failure event: %v", evt)
+	default:
+	}
+
+	all.Unsubscribe()
+	all.Unsubscribe()
+	if _, ok := <-all.Events(); ok {
+		t.Fatalf("channel of unsubscribed subscription is not closed")
+	}
+}
+
+// TestProviderEventsDropped validates the non-blocking delivery
+// of events to slow subscribers.
+func TestProviderEventsDropped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx)
+	var wg sync.WaitGroup
+	slow := p.Subscribe(2, services.EventBooked)
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		p.Book("foo", newCtxService(id, func(ctx context.Context) error { return nil }, &wg))
+	}
+
+	if dropped := slow.Dropped(); dropped != 3 {
+		t.Fatalf("invalid number of dropped events, expect 3: %d", dropped)
+	}
+	if evt := <-slow.Events(); evt.ServiceID != "a" {
+		t.Fatalf("invalid first event: %v", evt)
+	}
+}
@@ -0,0 +1,207 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outcome describes how a service execution ended.
+type Outcome int
+
+const (
+	// OutcomeSucceeded marks a successful execution.
+	OutcomeSucceeded Outcome = iota + 1
+
+	// OutcomeFailed marks a failed execution.
+	OutcomeFailed
+
+	// OutcomeCancelled marks an execution cancelled by its
+	// context.
+	OutcomeCancelled
+)
+
+// String implements fmt.Stringer.
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSucceeded:
+		return "succeeded"
+	case OutcomeFailed:
+		return "failed"
+	case OutcomeCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// outcomeOf returns the Outcome of an error.
+func outcomeOf(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeSucceeded
+	case errors.Is(err, context.Canceled):
+		return OutcomeCancelled
+	default:
+		return OutcomeFailed
+	}
+}
+
+// Run describes one execution of a service for a consumer.
+type Run struct {
+	ConsumerID string
+	ServiceID  string
+	Started    time.Time
+	Ended      time.Time
+	Outcome    Outcome
+	Err        string
+	Attempts   int
+}
+
+// newRun creates the Run of a Result.
+func newRun(consumerID string, result Result) Run {
+	run := Run{
+		ConsumerID: consumerID,
+		ServiceID:  result.ServiceID,
+		Started:    result.Started,
+		Ended:      result.Started.Add(result.Duration),
+		Outcome:    outcomeOf(result.Err),
+		Attempts:   result.Attempts,
+	}
+	if result.Err != nil {
+		run.Err = result.Err.Error()
+	}
+	return run
+}
+
+// Query filters the Runs of a History. Empty fields match all
+// Runs. A Limit larger than zero returns only the latest Runs.
+type Query struct {
+	ConsumerID string
+	ServiceID  string
+	Outcome    Outcome
+	Limit      int
+}
+
+// matches checks if a Run matches the Query.
+func (q Query) matches(run Run) bool {
+	if q.ConsumerID != "" && q.ConsumerID != run.ConsumerID {
+		return false
+	}
+	if q.ServiceID != "" && q.ServiceID != run.ServiceID {
+		return false
+	}
+	if q.Outcome != 0 && q.Outcome != run.Outcome {
+		return false
+	}
+	return true
+}
+
+// History records the executions of services. Implementations
+// have to be safe for concurrent usage.
+type History interface {
+	// Record adds a Run to the History.
+	Record(run Run) error
+
+	// Query returns the Runs matching the Query ordered by
+	// their start, oldest first.
+	Query(q Query) ([]Run, error)
+}
+
+// RingHistory is an in-memory History keeping the last Runs per
+// consumer and service.
+type RingHistory struct {
+	mu    sync.Mutex
+	size  int
+	rings map[string]map[string]*ring
+}
+
+// NewRingHistory creates a RingHistory keeping the last size Runs
+// per consumer and service.
+func NewRingHistory(size int) *RingHistory {
+	if size < 1 {
+		size = 1
+	}
+	return &RingHistory{
+		size:  size,
+		rings: make(map[string]map[string]*ring),
+	}
+}
+
+// Record implements History.
+func (h *RingHistory) Record(run Run) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rings, ok := h.rings[run.ConsumerID]
+	if !ok {
+		rings = make(map[string]*ring)
+		h.rings[run.ConsumerID] = rings
+	}
+	r, ok := rings[run.ServiceID]
+	if !ok {
+		r = &ring{runs: make([]Run, h.size)}
+		rings[run.ServiceID] = r
+	}
+	r.add(run)
+	return nil
+}
+
+// Query implements History.
+func (h *RingHistory) Query(q Query) ([]Run, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var runs []Run
+	for _, rings := range h.rings {
+		for _, r := range rings {
+			r.each(func(run Run) {
+				if q.matches(run) {
+					runs = append(runs, run)
+				}
+			})
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Started.Before(runs[j].Started)
+	})
+	if q.Limit > 0 && len(runs) > q.Limit {
+		runs = runs[len(runs)-q.Limit:]
+	}
+	return runs, nil
+}
+
+// ring is a fixed size buffer of Runs overwriting the oldest one.
+type ring struct {
+	runs []Run
+	next int
+	full bool
+}
+
+// add adds a Run.
+func (r *ring) add(run Run) {
+	r.runs[r.next] = run
+	r.next = (r.next + 1) % len(r.runs)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// each calls f for each Run, oldest first.
+func (r *ring) each(f func(run Run)) {
+	if r.full {
+		for _, run := range r.runs[r.next:] {
+			f(run)
+		}
+	}
+	for _, run := range r.runs[:r.next] {
+		f(run)
+	}
+}
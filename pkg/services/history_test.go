@@ -0,0 +1,105 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestRingHistory validates recording and querying Runs with
+// a RingHistory.
+func TestRingHistory(t *testing.T) {
+	h := services.NewRingHistory(3)
+	start := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		for _, consumerID := range []string{"x", "y"} {
+			outcome := services.OutcomeSucceeded
+			if i%2 == 1 {
+				outcome = services.OutcomeFailed
+			}
+			err := h.Record(services.Run{
+				ConsumerID: consumerID,
+				ServiceID:  "a",
+				Started:    start.Add(time.Duration(i) * time.Minute),
+				Outcome:    outcome,
+				Attempts:   i,
+			})
+			if err != nil {
+				t.Fatalf("recording failed: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    services.Query
+		attempts []int
+	}{
+		{"consumer", services.Query{ConsumerID: "x"}, []int{2, 3, 4}},
+		{"outcome", services.Query{ConsumerID: "y", Outcome: services.OutcomeFailed}, []int{3}},
+		{"limit", services.Query{ServiceID: "a", Limit: 2}, []int{4, 4}},
+		{"all", services.Query{}, []int{2, 2, 3, 3, 4, 4}},
+		{"none", services.Query{ServiceID: "b"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runs, err := h.Query(test.query)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if len(runs) != len(test.attempts) {
+				t.Fatalf("got %d runs, expect %d", len(runs), len(test.attempts))
+			}
+			for i, run := range runs {
+				if run.Attempts != test.attempts[i] {
+					t.Errorf("run %d has %d attempts, expect %d", i, run.Attempts, test.attempts[i])
+				}
+			}
+		})
+	}
+}
+
+// TestProviderHistory validates the recording of spawned services.
+func TestProviderHistory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := services.NewRingHistory(10)
+	p := services.StartProvider(ctx, services.WithHistory(h))
+	if _, err := p.Book("c", echoService{id: "ok"}, echoService{id: "fail", Fail: true}); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+	mustSpawn(t, p, "c").Wait()
+	mustSpawn(t, p, "c").Wait()
+
+	runs, err := h.Query(services.Query{ConsumerID: "c", Outcome: services.OutcomeFailed})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("got %d failed runs, expect 2", len(runs))
+	}
+	for _, run := range runs {
+		if run.ServiceID != "fail" || run.Err != "echo failed" || run.Attempts != 1 {
+			t.Errorf("unexpected run: %+v", run)
+		}
+		if run.Ended.Before(run.Started) {
+			t.Errorf("run ended before started: %+v", run)
+		}
+	}
+	runs, err = h.Query(services.Query{ServiceID: "ok"})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Outcome != services.OutcomeSucceeded {
+		t.Fatalf("unexpected successful runs: %+v", runs)
+	}
+}
@@ -0,0 +1,119 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"sort"
+	"time"
+)
+
+// BookingInfo contains the metadata of the booking of a service by
+// a consumer. LastResult is only valid if the service already has
+// been executed, the number of runs tells it.
+type BookingInfo struct {
+	ConsumerID string
+	ServiceID  string
+	BookedAt   time.Time
+	Persisted  bool
+	Runs       int
+	LastResult Result
+}
+
+// Consumers returns the IDs of all consumers having bookings. Like
+// all inspection methods it returns an empty result if the Provider
+// is stopped.
+func (p *Provider) Consumers() []string {
+	var consumerIDs []string
+	p.doSync(func() {
+		for consumerID := range p.bookings {
+			consumerIDs = append(consumerIDs, consumerID)
+		}
+	})
+	sort.Strings(consumerIDs)
+	return consumerIDs
+}
+
+// Booked returns the IDs of the services booked by a consumer.
+func (p *Provider) Booked(consumerID string) []string {
+	var svcIDs []string
+	p.doSync(func() {
+		for svcID := range p.bookings[consumerID] {
+			svcIDs = append(svcIDs, svcID)
+		}
+	})
+	sort.Strings(svcIDs)
+	return svcIDs
+}
+
+// BookedBy returns the IDs of all consumers booking the service
+// with the given ID.
+func (p *Provider) BookedBy(svcID string) []string {
+	var consumerIDs []string
+	p.doSync(func() {
+		for consumerID, svcs := range p.bookings {
+			if _, ok := svcs[svcID]; ok {
+				consumerIDs = append(consumerIDs, consumerID)
+			}
+		}
+	})
+	sort.Strings(consumerIDs)
+	return consumerIDs
+}
+
+// Booking returns the metadata of the booking of a service by a
+// consumer. The flag is false if there is no such booking.
+func (p *Provider) Booking(consumerID, svcID string) (BookingInfo, bool) {
+	var info BookingInfo
+	var ok bool
+	p.doSync(func() {
+		var bi *BookingInfo
+		bi, ok = p.infos[consumerID][svcID]
+		if ok {
+			info = p.bookingInfo(consumerID, bi)
+		}
+	})
+	return info, ok
+}
+
+// Bookings returns the metadata of all bookings of a consumer
+// ordered by service ID.
+func (p *Provider) Bookings(consumerID string) []BookingInfo {
+	var infos []BookingInfo
+	p.doSync(func() {
+		for _, bi := range p.infos[consumerID] {
+			infos = append(infos, p.bookingInfo(consumerID, bi))
+		}
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ServiceID < infos[j].ServiceID
+	})
+	return infos
+}
+
+// bookingInfo returns a copy of the metadata of a booking completed
+// by the current persistence state.
+func (p *Provider) bookingInfo(consumerID string, bi *BookingInfo) BookingInfo {
+	info := *bi
+	_, info.Persisted = p.specs[consumerID][bi.ServiceID]
+	return info
+}
+
+// recordResult updates the metadata of a booking by the Result of
+// its execution. It's done asynchronously as it is called by the
+// executing goroutines. The metadata of the booking at spawning time
+// is passed, so the comparison works with any service type.
+func (p *Provider) recordResult(consumerID string, bi *BookingInfo, result Result) {
+	p.doAsync(func() {
+		if bi == nil || p.infos[consumerID][result.ServiceID] != bi {
+			// Unbooked or replaced in the meantime.
+			return
+		}
+		bi.Runs++
+		bi.LastResult = result
+	})
+}
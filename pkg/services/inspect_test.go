@@ -0,0 +1,137 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestProviderInspection validates the reading of the bookings
+// and their metadata.
+func TestProviderInspection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := services.StartInMemoryBookingStore(ctx)
+	p := services.StartProvider(ctx, services.WithBookingStore(store), services.WithCatalog(newTestCatalog(t)))
+	var wg sync.WaitGroup
+	noop := func(ctx context.Context) error { return nil }
+	errOuch := errors.New("ouch")
+
+	before := time.Now()
+	p.Book("foo",
+		newCtxService("a", noop, &wg),
+		newCtxService("b", func(ctx context.Context) error { return errOuch }, &wg),
+	)
+	p.Book("bar", newCtxService("a", noop, &wg))
+	p.BookSpecs("bar", services.Spec{ServiceID: "c", Type: "echo", Config: json.RawMessage(`{}`)})
+
+	if consumerIDs := p.Consumers(); !reflect.DeepEqual(consumerIDs, []string{"bar", "foo"}) {
+		t.Fatalf("invalid consumers: %v", consumerIDs)
+	}
+	if svcIDs := p.Booked("bar"); !reflect.DeepEqual(svcIDs, []string{"a", "c"}) {
+		t.Fatalf("invalid services of bar: %v", svcIDs)
+	}
+	if svcIDs := p.Booked("unknown"); len(svcIDs) != 0 {
+		t.Fatalf("invalid services of unknown: %v", svcIDs)
+	}
+	if consumerIDs := p.BookedBy("a"); !reflect.DeepEqual(consumerIDs, []string{"bar", "foo"}) {
+		t.Fatalf("invalid consumers of a: %v", consumerIDs)
+	}
+	if consumerIDs := p.BookedBy("b"); !reflect.DeepEqual(consumerIDs, []string{"foo"}) {
+		t.Fatalf("invalid consumers of b: %v", consumerIDs)
+	}
+
+	info, ok := p.Booking("foo", "a")
+	if !ok {
+		t.Fatalf("booking of a by foo not found")
+	}
+	if info.BookedAt.Before(before) || info.Runs != 0 || info.Persisted {
+		t.Fatalf("invalid booking info: %v", info)
+	}
+	if info, _ = p.Booking("bar", "c"); !info.Persisted {
+		t.Fatalf("booking of c by bar is not persisted")
+	}
+	if _, ok = p.Booking("foo", "c"); ok {
+		t.Fatalf("found booking of c by foo")
+	}
+
+	wg.Add(4)
+	mustSpawn(t, p, "foo").Wait()
+	mustSpawn(t, p, "foo").Wait()
+
+	// Results are recorded asynchronously.
+	time.Sleep(10 * time.Millisecond)
+	infos := p.Bookings("foo")
+	if len(infos) != 2 {
+		t.Fatalf("invalid number of bookings of foo: %d", len(infos))
+	}
+	if infos[0].ServiceID != "a" || infos[0].Runs != 2 || infos[0].LastResult.Err != nil {
+		t.Fatalf("invalid booking info of a: %v", infos[0])
+	}
+	if infos[1].ServiceID != "b" || infos[1].Runs != 2 || infos[1].LastResult.Err != errOuch {
+		t.Fatalf("invalid booking info of b: %v", infos[1])
+	}
+
+	p.Unbook("foo", "a", "b")
+	if consumerIDs := p.Consumers(); !reflect.DeepEqual(consumerIDs, []string{"bar"}) {
+		t.Fatalf("invalid consumers after unbooking: %v", consumerIDs)
+	}
+	if infos = p.Bookings("foo"); len(infos) != 0 {
+		t.Fatalf("invalid bookings of foo after unbooking: %v", infos)
+	}
+}
+
+// valueService is a non-comparable Service implemented by a value
+// type containing a slice.
+type valueService struct {
+	id   string
+	tags []string
+}
+
+func (s valueService) ID() string {
+	return s.id
+}
+
+func (s valueService) Do() error {
+	return nil
+}
+
+// TestProviderRecordValueService validates that the results of
+// non-comparable services are recorded without panicking.
+func TestProviderRecordValueService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx)
+	p.Book("foo", valueService{id: "a", tags: []string{"x", "y"}})
+
+	if err := mustSpawn(t, p, "foo").Err(); err != nil {
+		t.Fatalf("spawning failed: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		info, ok := p.Booking("foo", "a")
+		if !ok {
+			t.Fatalf("booking is missing")
+		}
+		if info.Runs == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run has not been recorded: %v", info)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
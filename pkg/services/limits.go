@@ -0,0 +1,241 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRateLimited is wrapped by a LimitError if a rate limit
+	// has been exceeded.
+	ErrRateLimited = errors.New("rate limit exceeded")
+
+	// ErrQuotaExceeded is wrapped by a LimitError if a daily
+	// quota has been exceeded.
+	ErrQuotaExceeded = errors.New("daily quota exceeded")
+)
+
+// LimitError is returned if a spawning or a service execution is
+// rejected due to limits. The ServiceID is empty if the whole
+// spawning of a consumer is rejected.
+type LimitError struct {
+	ConsumerID string
+	ServiceID  string
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error implements the error interface.
+func (e *LimitError) Error() string {
+	if e.ServiceID == "" {
+		return fmt.Sprintf("consumer %q: %v, retry after %v", e.ConsumerID, e.Err, e.RetryAfter)
+	}
+	return fmt.Sprintf("consumer %q service %q: %v, retry after %v", e.ConsumerID, e.ServiceID, e.Err, e.RetryAfter)
+}
+
+// Unwrap returns ErrRateLimited or ErrQuotaExceeded.
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimit defines a token bucket. Each taken token is refilled
+// after the interval. Burst is the capacity of the bucket. A zero
+// interval means no limit.
+type RateLimit struct {
+	Interval time.Duration
+	Burst    int
+}
+
+// Limits defines the limits of a consumer.
+type Limits struct {
+	// Spawns limits the rate of spawnings.
+	Spawns RateLimit
+
+	// DailyQuota limits the number of service executions per
+	// day. Zero means no limit.
+	DailyQuota int
+}
+
+// normalize returns the RateLimit with a burst of at least one.
+func (rl RateLimit) normalize() RateLimit {
+	if rl.Burst < 1 {
+		rl.Burst = 1
+	}
+	return rl
+}
+
+// tokenBucket implements a RateLimit.
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket.
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	limit = limit.normalize()
+	return &tokenBucket{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   now,
+	}
+}
+
+// take takes a token if available. Otherwise the duration until
+// the next token is available is returned.
+func (tb *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	elapsed := now.Sub(tb.last)
+	tb.last = now
+	tb.tokens += float64(elapsed) / float64(tb.limit.Interval)
+	if tb.tokens > float64(tb.limit.Burst) {
+		tb.tokens = float64(tb.limit.Burst)
+	}
+	if tb.tokens >= 1 {
+		tb.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - tb.tokens) * float64(tb.limit.Interval))
+}
+
+// usage counts the executions of a consumer per day.
+type usage struct {
+	day   string
+	count int
+}
+
+// limiter enforces the limits of consumers and services.
+type limiter struct {
+	mu              sync.Mutex
+	location        *time.Location
+	defaults        Limits
+	consumerLimits  map[string]Limits
+	serviceLimits   map[string]RateLimit
+	consumerBuckets map[string]*tokenBucket
+	serviceBuckets  map[string]*tokenBucket
+	usages          map[string]*usage
+}
+
+// newLimiter creates a limiter without any limits.
+func newLimiter() *limiter {
+	return &limiter{
+		location:        time.Local,
+		consumerLimits:  make(map[string]Limits),
+		serviceLimits:   make(map[string]RateLimit),
+		consumerBuckets: make(map[string]*tokenBucket),
+		serviceBuckets:  make(map[string]*tokenBucket),
+		usages:          make(map[string]*usage),
+	}
+}
+
+// limits returns the limits of a consumer.
+func (l *limiter) limits(consumerID string) Limits {
+	if limits, ok := l.consumerLimits[consumerID]; ok {
+		return limits
+	}
+	return l.defaults
+}
+
+// allowSpawn checks if the consumer may spawn the given number of
+// services. In that case the usage is counted.
+func (l *limiter) allowSpawn(consumerID string, svcCnt int, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limits := l.limits(consumerID)
+	day := now.In(l.location).Format("2006-01-02")
+	u, ok := l.usages[consumerID]
+	if !ok || u.day != day {
+		u = &usage{day: day}
+		l.usages[consumerID] = u
+	}
+	if limits.DailyQuota > 0 && u.count+svcCnt > limits.DailyQuota {
+		local := now.In(l.location)
+		tomorrow := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, l.location)
+		return &LimitError{
+			ConsumerID: consumerID,
+			RetryAfter: tomorrow.Sub(now),
+			Err:        ErrQuotaExceeded,
+		}
+	}
+	if limits.Spawns.Interval > 0 {
+		tb, ok := l.consumerBuckets[consumerID]
+		// Compare normalized, so that an unset burst doesn't
+		// refill the bucket each time.
+		if !ok || tb.limit != limits.Spawns.normalize() {
+			tb = newTokenBucket(limits.Spawns, now)
+			l.consumerBuckets[consumerID] = tb
+		}
+		if ok, retryAfter := tb.take(now); !ok {
+			return &LimitError{
+				ConsumerID: consumerID,
+				RetryAfter: retryAfter,
+				Err:        ErrRateLimited,
+			}
+		}
+	}
+	u.count += svcCnt
+	return nil
+}
+
+// allowService checks the rate limit of a service.
+func (l *limiter) allowService(consumerID, svcID string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit, ok := l.serviceLimits[svcID]
+	if !ok || limit.Interval <= 0 {
+		return nil
+	}
+	tb, ok := l.serviceBuckets[svcID]
+	if !ok {
+		tb = newTokenBucket(limit, now)
+		l.serviceBuckets[svcID] = tb
+	}
+	if ok, retryAfter := tb.take(now); !ok {
+		return &LimitError{
+			ConsumerID: consumerID,
+			ServiceID:  svcID,
+			RetryAfter: retryAfter,
+			Err:        ErrRateLimited,
+		}
+	}
+	return nil
+}
+
+// WithLimits sets the default Limits for all consumers. The day of
+// the daily quotas is determined in the given location, by default
+// the local one.
+func WithLimits(limits Limits, location *time.Location) Option {
+	return func(p *Provider) {
+		p.limiter.defaults = limits
+		if location != nil {
+			p.limiter.location = location
+		}
+	}
+}
+
+// WithConsumerLimits sets the Limits of one consumer replacing the
+// default Limits.
+func WithConsumerLimits(consumerID string, limits Limits) Option {
+	return func(p *Provider) {
+		p.limiter.consumerLimits[consumerID] = limits
+	}
+}
+
+// WithServiceRateLimits limits the rate of the executions of the
+// services with the given IDs. Each service has its own bucket
+// shared by all consumers.
+func WithServiceRateLimits(limit RateLimit, svcIDs ...string) Option {
+	return func(p *Provider) {
+		for _, svcID := range svcIDs {
+			p.limiter.serviceLimits[svcID] = limit
+		}
+	}
+}
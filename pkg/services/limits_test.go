@@ -0,0 +1,133 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestProviderSpawnRateLimit validates the rejection of spawnings
+// exceeding the rate limit of a consumer.
+func TestProviderSpawnRateLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx,
+		services.WithLimits(services.Limits{
+			Spawns: services.RateLimit{Interval: time.Hour, Burst: 2},
+		}, nil),
+		services.WithConsumerLimits("fast", services.Limits{
+			Spawns: services.RateLimit{Interval: 20 * time.Millisecond, Burst: 1},
+		}),
+		services.WithConsumerLimits("noburst", services.Limits{
+			Spawns: services.RateLimit{Interval: time.Hour},
+		}),
+	)
+	for _, consumerID := range []string{"slow", "fast", "noburst"} {
+		if _, err := p.Book(consumerID, echoService{id: "a"}); err != nil {
+			t.Fatalf("booking failed: %v", err)
+		}
+	}
+
+	mustSpawn(t, p, "slow").Wait()
+	mustSpawn(t, p, "slow").Wait()
+	_, err := p.Spawn("slow")
+	if !errors.Is(err, services.ErrRateLimited) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	var lerr *services.LimitError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("expected LimitError, got %T", err)
+	}
+	if lerr.ConsumerID != "slow" || lerr.RetryAfter <= 30*time.Minute || lerr.RetryAfter > time.Hour {
+		t.Fatalf("unexpected limit error: %v", lerr)
+	}
+
+	mustSpawn(t, p, "fast").Wait()
+	if _, err := p.Spawn("fast"); !errors.Is(err, services.ErrRateLimited) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	time.Sleep(25 * time.Millisecond)
+	mustSpawn(t, p, "fast").Wait()
+
+	// Unset burst means a burst of one.
+	mustSpawn(t, p, "noburst").Wait()
+	if _, err := p.Spawn("noburst"); !errors.Is(err, services.ErrRateLimited) {
+		t.Fatalf("expected rate limit error without burst, got %v", err)
+	}
+}
+
+// TestProviderDailyQuota validates the rejection of spawnings
+// exceeding the daily quota of a consumer.
+func TestProviderDailyQuota(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithLimits(services.Limits{DailyQuota: 3}, time.UTC))
+	if _, err := p.Book("c", echoService{id: "a"}, echoService{id: "b"}); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+
+	mustSpawn(t, p, "c").Wait()
+	_, err := p.Spawn("c")
+	if !errors.Is(err, services.ErrQuotaExceeded) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	var lerr *services.LimitError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("expected LimitError, got %T", err)
+	}
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	if reset := now.Add(lerr.RetryAfter); reset.Sub(midnight) > time.Second || midnight.Sub(reset) > time.Second {
+		t.Fatalf("retry after %v does not end at midnight", lerr.RetryAfter)
+	}
+
+	if _, err := p.Unbook("c", "b"); err != nil {
+		t.Fatalf("unbooking failed: %v", err)
+	}
+	mustSpawn(t, p, "c").Wait()
+}
+
+// TestProviderServiceRateLimit validates the rejection of single
+// service executions exceeding their rate limit.
+func TestProviderServiceRateLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithServiceRateLimits(services.RateLimit{
+		Interval: time.Hour,
+		Burst:    1,
+	}, "a"))
+	for _, consumerID := range []string{"x", "y"} {
+		if _, err := p.Book(consumerID, echoService{id: "a"}, echoService{id: "b"}); err != nil {
+			t.Fatalf("booking failed: %v", err)
+		}
+	}
+
+	s := mustSpawn(t, p, "x")
+	s.Wait()
+	if err := s.Err(); err != nil {
+		t.Fatalf("first spawning failed: %v", err)
+	}
+	s = mustSpawn(t, p, "y")
+	s.Wait()
+	a, _ := s.Result("a")
+	if !errors.Is(a.Err, services.ErrRateLimited) {
+		t.Fatalf("expected rate limit error for a, got %v", a.Err)
+	}
+	var lerr *services.LimitError
+	if !errors.As(a.Err, &lerr) || lerr.ServiceID != "a" || lerr.ConsumerID != "y" {
+		t.Fatalf("unexpected limit error: %v", a.Err)
+	}
+	if b, _ := s.Result("b"); b.Err != nil {
+		t.Fatalf("b failed: %v", b.Err)
+	}
+}
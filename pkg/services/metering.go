@@ -0,0 +1,269 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/themue/samples/pkg/consumers"
+)
+
+// BillingPeriod defines the period the usage is aggregated for.
+type BillingPeriod int
+
+const (
+	// BillingDaily aggregates the usage per day.
+	BillingDaily BillingPeriod = iota + 1
+
+	// BillingWeekly aggregates the usage per week starting
+	// on Monday.
+	BillingWeekly
+
+	// BillingMonthly aggregates the usage per month.
+	BillingMonthly
+)
+
+// String implements fmt.Stringer.
+func (bp BillingPeriod) String() string {
+	switch bp {
+	case BillingDaily:
+		return "daily"
+	case BillingWeekly:
+		return "weekly"
+	case BillingMonthly:
+		return "monthly"
+	default:
+		return "unknown"
+	}
+}
+
+// Bounds returns start and end of the billing period containing
+// the time t in the given location.
+func (bp BillingPeriod) Bounds(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	t = t.In(loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	switch bp {
+	case BillingWeekly:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	case BillingMonthly:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// Usage contains the aggregated executions of a service by a
+// consumer in one billing period. In JSON the duration is written
+// in milliseconds like in CSV.
+type Usage struct {
+	ConsumerID   string        `json:"consumerId"`
+	ConsumerName string        `json:"consumerName,omitempty"`
+	ServiceID    string        `json:"serviceId"`
+	PeriodStart  time.Time     `json:"periodStart"`
+	PeriodEnd    time.Time     `json:"periodEnd"`
+	Executions   int           `json:"executions"`
+	Failures     int           `json:"failures"`
+	Duration     time.Duration `json:"-"`
+}
+
+// jsonUsage is the JSON representation of a Usage.
+type jsonUsage struct {
+	plainUsage
+	DurationMs int64 `json:"durationMs"`
+}
+
+// plainUsage avoids the recursion when marshalling a Usage.
+type plainUsage Usage
+
+// MarshalJSON implements json.Marshaler.
+func (u Usage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonUsage{
+		plainUsage: plainUsage(u),
+		DurationMs: u.Duration.Milliseconds(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (u *Usage) UnmarshalJSON(data []byte) error {
+	var ju jsonUsage
+	if err := json.Unmarshal(data, &ju); err != nil {
+		return err
+	}
+	*u = Usage(ju.plainUsage)
+	u.Duration = time.Duration(ju.DurationMs) * time.Millisecond
+	return nil
+}
+
+// usageKey identifies a Usage.
+type usageKey struct {
+	consumerID  string
+	svcID       string
+	periodStart time.Time
+}
+
+// Meter aggregates the usage of services per consumer and billing
+// period. It is safe for concurrent usage.
+type Meter struct {
+	mu       sync.Mutex
+	period   BillingPeriod
+	location *time.Location
+	usages   map[usageKey]*Usage
+}
+
+// NewMeter creates a Meter aggregating per billing period. The
+// periods are determined in the given location, by default the
+// local one.
+func NewMeter(period BillingPeriod, location *time.Location) *Meter {
+	if location == nil {
+		location = time.Local
+	}
+	return &Meter{
+		period:   period,
+		location: location,
+		usages:   make(map[usageKey]*Usage),
+	}
+}
+
+// Record adds the Result of a service execution by a consumer.
+// Executions which have been rejected before running are not metered.
+func (m *Meter) Record(consumerID string, result Result) {
+	if rejected(result.Err) {
+		return
+	}
+	start, end := m.period.Bounds(result.Started, m.location)
+	key := usageKey{
+		consumerID:  consumerID,
+		svcID:       result.ServiceID,
+		periodStart: start,
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.usages[key]
+	if !ok {
+		u = &Usage{
+			ConsumerID:  consumerID,
+			ServiceID:   result.ServiceID,
+			PeriodStart: start,
+			PeriodEnd:   end,
+		}
+		m.usages[key] = u
+	}
+	u.Executions++
+	if result.Err != nil {
+		u.Failures++
+	}
+	u.Duration += result.Duration
+}
+
+// Usages returns the Usages of the billing periods starting in the
+// interval [from, to) ordered by period, consumer, and service. Zero
+// times are not limiting.
+func (m *Meter) Usages(from, to time.Time) []Usage {
+	m.mu.Lock()
+	var usages []Usage
+	for _, u := range m.usages {
+		if !from.IsZero() && u.PeriodStart.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !u.PeriodStart.Before(to) {
+			continue
+		}
+		usages = append(usages, *u)
+	}
+	m.mu.Unlock()
+	sort.Slice(usages, func(i, j int) bool {
+		ui, uj := usages[i], usages[j]
+		if !ui.PeriodStart.Equal(uj.PeriodStart) {
+			return ui.PeriodStart.Before(uj.PeriodStart)
+		}
+		if ui.ConsumerID != uj.ConsumerID {
+			return ui.ConsumerID < uj.ConsumerID
+		}
+		return ui.ServiceID < uj.ServiceID
+	})
+	return usages
+}
+
+// ConsumerReader reads consumers by ID. It is implemented by
+// the consumers.Controller.
+type ConsumerReader interface {
+	Read(id string) (consumers.Consumer, error)
+}
+
+// ResolveConsumerNames sets the consumer names of the Usages read
+// by the reader. The names of unknown consumers stay empty.
+func ResolveConsumerNames(usages []Usage, reader ConsumerReader) {
+	names := make(map[string]string)
+	for i := range usages {
+		id := usages[i].ConsumerID
+		name, ok := names[id]
+		if !ok {
+			if c, err := reader.Read(id); err == nil {
+				name = c.Name
+			}
+			names[id] = name
+		}
+		usages[i].ConsumerName = name
+	}
+}
+
+// WriteUsagesCSV writes the Usages as CSV with header. Times are
+// formatted as RFC 3339, durations in milliseconds.
+func WriteUsagesCSV(w io.Writer, usages []Usage) error {
+	cw := csv.NewWriter(w)
+	header := []string{
+		"consumer_id", "consumer_name", "service_id", "period_start",
+		"period_end", "executions", "failures", "duration_ms",
+	}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("cannot write CSV header: %w", err)
+	}
+	for _, u := range usages {
+		record := []string{
+			u.ConsumerID,
+			u.ConsumerName,
+			u.ServiceID,
+			u.PeriodStart.Format(time.RFC3339),
+			u.PeriodEnd.Format(time.RFC3339),
+			strconv.Itoa(u.Executions),
+			strconv.Itoa(u.Failures),
+			strconv.FormatInt(u.Duration.Milliseconds(), 10),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("cannot write CSV record: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("cannot write CSV: %w", err)
+	}
+	return nil
+}
+
+// WriteUsagesJSON writes the Usages as JSON array. Durations are
+// written in milliseconds.
+func WriteUsagesJSON(w io.Writer, usages []Usage) error {
+	if usages == nil {
+		usages = []Usage{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(usages); err != nil {
+		return fmt.Errorf("cannot write JSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/consumers"
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestBillingPeriodBounds validates the bounds of the billing periods.
+func TestBillingPeriodBounds(t *testing.T) {
+	at := time.Date(2021, time.March, 17, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		period services.BillingPeriod
+		start  time.Time
+		end    time.Time
+	}{
+		{services.BillingDaily, time.Date(2021, time.March, 17, 0, 0, 0, 0, time.UTC), time.Date(2021, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{services.BillingWeekly, time.Date(2021, time.March, 15, 0, 0, 0, 0, time.UTC), time.Date(2021, time.March, 22, 0, 0, 0, 0, time.UTC)},
+		{services.BillingMonthly, time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		start, end := test.period.Bounds(at, time.UTC)
+		if !start.Equal(test.start) || !end.Equal(test.end) {
+			t.Errorf("%v bounds are %v - %v, expect %v - %v", test.period, start, end, test.start, test.end)
+		}
+	}
+}
+
+// TestMeterExport validates the aggregation and export of Usages.
+func TestMeterExport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := services.NewMeter(services.BillingMonthly, time.UTC)
+	march := time.Date(2021, time.March, 3, 10, 0, 0, 0, time.UTC)
+	april := time.Date(2021, time.April, 3, 10, 0, 0, 0, time.UTC)
+	m.Record("x", services.Result{ServiceID: "a", Started: march, Duration: time.Second})
+	m.Record("x", services.Result{ServiceID: "a", Started: march, Duration: 2 * time.Second, Err: errors.New("ouch")})
+	m.Record("x", services.Result{ServiceID: "a", Started: march, Err: &services.LimitError{}})
+	m.Record("y", services.Result{ServiceID: "a", Started: march, Duration: time.Second})
+	m.Record("x", services.Result{ServiceID: "a", Started: april, Duration: time.Second})
+
+	usages := m.Usages(time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC))
+	if len(usages) != 2 {
+		t.Fatalf("got %d usages, expect 2", len(usages))
+	}
+	x := usages[0]
+	if x.ConsumerID != "x" || x.Executions != 2 || x.Failures != 1 || x.Duration != 3*time.Second {
+		t.Fatalf("unexpected usage: %+v", x)
+	}
+	if all := m.Usages(time.Time{}, time.Time{}); len(all) != 3 {
+		t.Fatalf("got %d usages, expect 3", len(all))
+	}
+
+	cc := consumers.StartController(ctx, consumers.StartInMemoryStore(ctx))
+	if err := cc.Add(consumers.Consumer{ID: "x", Name: "Team X"}); err != nil {
+		t.Fatalf("adding consumer failed: %v", err)
+	}
+	services.ResolveConsumerNames(usages, cc)
+	if usages[0].ConsumerName != "Team X" || usages[1].ConsumerName != "" {
+		t.Fatalf("unexpected consumer names: %q, %q", usages[0].ConsumerName, usages[1].ConsumerName)
+	}
+
+	var buf bytes.Buffer
+	if err := services.WriteUsagesCSV(&buf, usages); err != nil {
+		t.Fatalf("writing CSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := "x,Team X,a,2021-03-01T00:00:00Z,2021-04-01T00:00:00Z,2,1,3000"
+	if len(lines) != 3 || lines[1] != expected {
+		t.Fatalf("unexpected CSV:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := services.WriteUsagesJSON(&buf, usages); err != nil {
+		t.Fatalf("writing JSON failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"durationMs": 3000`) {
+		t.Fatalf("JSON duration is not in milliseconds:\n%s", buf.String())
+	}
+	var decoded []services.Usage
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("decoding JSON failed: %v", err)
+	}
+	if len(decoded) != 2 || decoded[0] != usages[0] {
+		t.Fatalf("unexpected JSON:\n%s", buf.String())
+	}
+}
+
+// TestProviderMeter validates the metering of spawned services.
+func TestProviderMeter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := services.NewMeter(services.BillingDaily, nil)
+	p := services.StartProvider(ctx, services.WithMeter(m))
+	if _, err := p.Book("c", echoService{id: "a"}, echoService{id: "b", Fail: true}); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+	mustSpawn(t, p, "c").Wait()
+	mustSpawn(t, p, "c").Wait()
+
+	usages := m.Usages(time.Time{}, time.Time{})
+	if len(usages) != 2 {
+		t.Fatalf("got %d usages, expect 2", len(usages))
+	}
+	if usages[0].ServiceID != "a" || usages[0].Executions != 2 || usages[0].Failures != 0 {
+		t.Errorf("unexpected usage of a: %+v", usages[0])
+	}
+	if usages[1].ServiceID != "b" || usages[1].Executions != 2 || usages[1].Failures != 2 {
+		t.Errorf("unexpected usage of b: %+v", usages[1])
+	}
+}
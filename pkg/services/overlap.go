@@ -0,0 +1,176 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrOverlapSkipped is returned as result of a service execution
+// which has been skipped because the same booking is still running.
+var ErrOverlapSkipped = errors.New("execution skipped, booking still running")
+
+// OverlapPolicy defines how the Provider handles the execution of
+// a booked service while the previous execution of the same booking
+// is still running.
+type OverlapPolicy int
+
+const (
+	// OverlapAllow runs the executions concurrently.
+	OverlapAllow OverlapPolicy = iota
+
+	// OverlapSkip skips the new execution.
+	OverlapSkip
+
+	// OverlapQueueOne lets the new execution wait until the running
+	// one is done. Further executions are skipped while one is waiting.
+	OverlapQueueOne
+
+	// OverlapReplace cancels the running execution and starts the
+	// new one when the cancelled one is done.
+	OverlapReplace
+)
+
+// String implements fmt.Stringer.
+func (op OverlapPolicy) String() string {
+	switch op {
+	case OverlapAllow:
+		return "allow"
+	case OverlapSkip:
+		return "skip"
+	case OverlapQueueOne:
+		return "queue-one"
+	case OverlapReplace:
+		return "replace"
+	default:
+		return "unknown"
+	}
+}
+
+// WithOverlapPolicies sets the OverlapPolicy of the services with
+// the given IDs. The default is OverlapAllow.
+func WithOverlapPolicies(policy OverlapPolicy, svcIDs ...string) Option {
+	return func(p *Provider) {
+		for _, svcID := range svcIDs {
+			p.overlaps.policies[svcID] = policy
+		}
+	}
+}
+
+// bookingKey identifies the booking of a service by a consumer.
+type bookingKey struct {
+	consumerID string
+	svcID      string
+}
+
+// overlapState is the execution state of one booking.
+type overlapState struct {
+	running bool
+	pending bool
+	waiting int
+	cancel  context.CancelFunc
+	doneC   chan struct{}
+}
+
+// overlapGuard enforces the OverlapPolicies of the bookings.
+type overlapGuard struct {
+	mu       sync.Mutex
+	policies map[string]OverlapPolicy
+	states   map[bookingKey]*overlapState
+}
+
+// newOverlapGuard creates an overlapGuard without policies.
+func newOverlapGuard() *overlapGuard {
+	return &overlapGuard{
+		policies: make(map[string]OverlapPolicy),
+		states:   make(map[bookingKey]*overlapState),
+	}
+}
+
+// acquire checks if a booked service may be executed according to
+// its policy, waiting if needed. It returns the context for the
+// execution and the function to call when it is done.
+func (og *overlapGuard) acquire(ctx context.Context, consumerID, svcID string) (context.Context, func(), error) {
+	policy := og.policies[svcID]
+	if policy == OverlapAllow {
+		return ctx, func() {}, nil
+	}
+	key := bookingKey{consumerID, svcID}
+	og.mu.Lock()
+	defer og.mu.Unlock()
+	st, ok := og.states[key]
+	if !ok {
+		st = &overlapState{}
+		og.states[key] = st
+	}
+	for st.running {
+		switch policy {
+		case OverlapSkip:
+			return nil, nil, ErrOverlapSkipped
+		case OverlapQueueOne:
+			if st.pending {
+				return nil, nil, ErrOverlapSkipped
+			}
+			st.pending = true
+			err := og.await(ctx, key, st)
+			st.pending = false
+			if err != nil {
+				return nil, nil, err
+			}
+		case OverlapReplace:
+			st.cancel()
+			if err := og.await(ctx, key, st); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	execCtx, cancel := context.WithCancel(ctx)
+	doneC := make(chan struct{})
+	st.running = true
+	st.cancel = cancel
+	st.doneC = doneC
+	release := func() {
+		og.mu.Lock()
+		defer og.mu.Unlock()
+		cancel()
+		st.running = false
+		close(doneC)
+		og.drop(key, st)
+	}
+	return execCtx, release, nil
+}
+
+// await waits unlocked until the running execution is done or the
+// context is cancelled.
+func (og *overlapGuard) await(ctx context.Context, key bookingKey, st *overlapState) error {
+	doneC := st.doneC
+	st.waiting++
+	og.mu.Unlock()
+	var err error
+	select {
+	case <-doneC:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	og.mu.Lock()
+	st.waiting--
+	if err != nil {
+		og.drop(key, st)
+	}
+	return err
+}
+
+// drop removes the state of a booking when it is neither running
+// nor awaited anymore, so that the states don't grow endlessly.
+func (og *overlapGuard) drop(key bookingKey, st *overlapState) {
+	if !st.running && st.waiting == 0 {
+		delete(og.states, key)
+	}
+}
@@ -0,0 +1,144 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestProviderOverlapPolicies validates the handling of executions
+// of the same booking while the previous one is still running.
+func TestProviderOverlapPolicies(t *testing.T) {
+	tests := []struct {
+		policy   services.OverlapPolicy
+		expected []error
+		runs     int
+	}{
+		{services.OverlapAllow, []error{nil, nil, nil}, 3},
+		{services.OverlapSkip, []error{nil, services.ErrOverlapSkipped, services.ErrOverlapSkipped}, 1},
+		{services.OverlapQueueOne, []error{nil, nil, services.ErrOverlapSkipped}, 2},
+		{services.OverlapReplace, []error{context.Canceled, context.Canceled, nil}, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			p := services.StartProvider(ctx, services.WithOverlapPolicies(test.policy, "block"))
+			svc := newBlockingService("block")
+			if _, err := p.Book("c", svc); err != nil {
+				t.Fatalf("booking failed: %v", err)
+			}
+
+			var spawnings []*services.Spawning
+			for i := 0; i < 3; i++ {
+				spawnings = append(spawnings, mustSpawn(t, p, "c"))
+				// Let the execution start or wait before spawning again.
+				time.Sleep(20 * time.Millisecond)
+			}
+			close(svc.releaseC)
+			for i, s := range spawnings {
+				s.Wait()
+				result, _ := s.Result("block")
+				if !errors.Is(result.Err, test.expected[i]) || (test.expected[i] == nil && result.Err != nil) {
+					t.Errorf("spawning %d returned %v, expect %v", i, result.Err, test.expected[i])
+				}
+			}
+			if runs := svc.runs(); runs != test.runs {
+				t.Errorf("service ran %d times, expect %d", runs, test.runs)
+			}
+		})
+	}
+}
+
+// TestProviderSpawnQueued validates that repeated spawns of a
+// service not synchronizing its state are serialized by the
+// OverlapQueueOne policy.
+func TestProviderSpawnQueued(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithOverlapPolicies(services.OverlapQueueOne, "count"))
+	svc := &countingService{id: "count"}
+	if _, err := p.Book("c", svc); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+
+	sa := mustSpawn(t, p, "c")
+	sb := mustSpawn(t, p, "c")
+	if err := sa.Err(); err != nil {
+		t.Fatalf("first spawning failed: %v", err)
+	}
+	if err := sb.Err(); err != nil {
+		t.Fatalf("second spawning failed: %v", err)
+	}
+	if svc.count != 2 {
+		t.Fatalf("service ran %d times, expect 2", svc.count)
+	}
+}
+
+// blockingService blocks until released or cancelled. Each start
+// is signalled via startedC.
+type blockingService struct {
+	id       string
+	releaseC chan struct{}
+	countC   chan struct{}
+	startedC chan struct{}
+}
+
+func newBlockingService(id string) *blockingService {
+	return &blockingService{
+		id:       id,
+		releaseC: make(chan struct{}),
+		countC:   make(chan struct{}, 16),
+		startedC: make(chan struct{}, 16),
+	}
+}
+
+func (s *blockingService) ID() string {
+	return s.id
+}
+
+func (s *blockingService) Do() error {
+	return s.DoContext(context.Background())
+}
+
+func (s *blockingService) DoContext(ctx context.Context) error {
+	s.countC <- struct{}{}
+	s.startedC <- struct{}{}
+	select {
+	case <-s.releaseC:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *blockingService) runs() int {
+	return len(s.countC)
+}
+
+// countingService counts its executions without any
+// synchronization.
+type countingService struct {
+	id    string
+	count int
+}
+
+func (s *countingService) ID() string {
+	return s.id
+}
+
+func (s *countingService) Do() error {
+	time.Sleep(5 * time.Millisecond)
+	s.count++
+	return nil
+}
@@ -0,0 +1,346 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrPoolExhausted is returned as result of a service execution
+// which has been rejected by the execution pool.
+var ErrPoolExhausted = errors.New("execution pool exhausted")
+
+// RejectionPolicy defines how the execution pool handles new
+// executions when its queue is full.
+type RejectionPolicy int
+
+const (
+	// RejectNew rejects the new execution.
+	RejectNew RejectionPolicy = iota
+
+	// DropOldest rejects the longest waiting execution and
+	// queues the new one.
+	DropOldest
+)
+
+// PoolConfig configures the execution pool of a Provider. Zero
+// values mean no limit.
+type PoolConfig struct {
+	// MaxConcurrency limits the number of services executed
+	// concurrently.
+	MaxConcurrency int
+
+	// MaxPerConsumer limits the number of services executed
+	// concurrently for one consumer.
+	MaxPerConsumer int
+
+	// QueueLength limits the number of executions waiting
+	// for a free slot.
+	QueueLength int
+
+	// Rejection defines the handling of new executions when
+	// the queue is full.
+	Rejection RejectionPolicy
+
+	// Fair starts waiting executions by weighted round-robin
+	// across the consumers instead of first in, first out. So
+	// a consumer with many services cannot starve the others.
+	Fair bool
+
+	// Weights defines how many waiting executions of a consumer
+	// are started per round when Fair is set. The default weight
+	// is 1.
+	Weights map[string]int
+}
+
+// task is one service execution inside the pool.
+type task struct {
+	seq        uint64
+	consumerID string
+	run        func()
+	reject     func(err error)
+}
+
+// pool limits the concurrent execution of tasks. Tasks exceeding
+// the limits are queued.
+type pool struct {
+	mu          sync.Mutex
+	cfg         PoolConfig
+	seq         uint64
+	running     int
+	perConsumer map[string]int
+	queue       taskQueue
+}
+
+// newPool creates a pool with the given configuration.
+func newPool(cfg PoolConfig) *pool {
+	var queue taskQueue = &fifoQueue{}
+	if cfg.Fair {
+		weights := make(map[string]int, len(cfg.Weights))
+		for consumerID, weight := range cfg.Weights {
+			weights[consumerID] = weight
+		}
+		queue = newFairQueue(weights)
+	}
+	return &pool{
+		cfg:         cfg,
+		perConsumer: make(map[string]int),
+		queue:       queue,
+	}
+}
+
+// submit starts the task if the limits allow it. Otherwise it
+// is queued or, if the queue is full, rejected according to the
+// configured policy.
+func (pl *pool) submit(t *task) {
+	var rejected *task
+	pl.mu.Lock()
+	pl.seq++
+	t.seq = pl.seq
+	switch {
+	case pl.canStart(t):
+		pl.start(t)
+	case pl.cfg.QueueLength > 0 && pl.queue.len() >= pl.cfg.QueueLength:
+		if pl.cfg.Rejection == DropOldest {
+			rejected = pl.queue.dropOldest()
+			pl.queue.push(t)
+		} else {
+			rejected = t
+		}
+	default:
+		pl.queue.push(t)
+	}
+	pl.mu.Unlock()
+	if rejected != nil {
+		rejected.reject(ErrPoolExhausted)
+	}
+}
+
+// withdraw removes a task still waiting in the queue, e.g. because
+// its execution has been cancelled. It returns false if the task
+// has already been started or rejected.
+func (pl *pool) withdraw(t *task) bool {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.queue.withdraw(t)
+}
+
+// canStart checks if the limits allow to start the task.
+func (pl *pool) canStart(t *task) bool {
+	if pl.cfg.MaxConcurrency > 0 && pl.running >= pl.cfg.MaxConcurrency {
+		return false
+	}
+	if pl.cfg.MaxPerConsumer > 0 && pl.perConsumer[t.consumerID] >= pl.cfg.MaxPerConsumer {
+		return false
+	}
+	return true
+}
+
+// start runs the task in a goroutine.
+func (pl *pool) start(t *task) {
+	pl.running++
+	pl.perConsumer[t.consumerID]++
+	go func() {
+		t.run()
+		pl.done(t)
+	}()
+}
+
+// done releases the slot of the task and starts waiting tasks
+// as far as the limits allow.
+func (pl *pool) done(t *task) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.running--
+	pl.perConsumer[t.consumerID]--
+	if pl.perConsumer[t.consumerID] == 0 {
+		delete(pl.perConsumer, t.consumerID)
+	}
+	for {
+		qt := pl.queue.next(pl.canStart)
+		if qt == nil {
+			return
+		}
+		pl.start(qt)
+	}
+}
+
+// taskQueue contains the tasks waiting for a free slot.
+type taskQueue interface {
+	// push adds a task.
+	push(t *task)
+
+	// next removes and returns the next task allowed to start
+	// or nil if there is none.
+	next(canStart func(t *task) bool) *task
+
+	// dropOldest removes and returns the longest waiting task.
+	dropOldest() *task
+
+	// withdraw removes the task if it is still waiting.
+	withdraw(t *task) bool
+
+	// len returns the number of waiting tasks.
+	len() int
+}
+
+// fifoQueue starts the tasks in the order they have been queued.
+type fifoQueue struct {
+	tasks []*task
+}
+
+func (q *fifoQueue) push(t *task) {
+	q.tasks = append(q.tasks, t)
+}
+
+func (q *fifoQueue) next(canStart func(t *task) bool) *task {
+	for i, t := range q.tasks {
+		if canStart(t) {
+			q.tasks = append(q.tasks[:i], q.tasks[i+1:]...)
+			return t
+		}
+	}
+	return nil
+}
+
+func (q *fifoQueue) dropOldest() *task {
+	t := q.tasks[0]
+	q.tasks = q.tasks[1:]
+	return t
+}
+
+func (q *fifoQueue) withdraw(t *task) bool {
+	for i, qt := range q.tasks {
+		if qt == t {
+			q.tasks = append(q.tasks[:i], q.tasks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *fifoQueue) len() int {
+	return len(q.tasks)
+}
+
+// fairQueue starts the tasks by weighted round-robin across the
+// consumers. Each consumer in turn may start as many tasks as its
+// weight before the next consumer follows.
+type fairQueue struct {
+	weights map[string]int
+	queues  map[string][]*task
+	order   []string
+	cursor  int
+	credit  int
+	granted bool
+	size    int
+}
+
+// newFairQueue creates a fairQueue with the weights of the consumers.
+func newFairQueue(weights map[string]int) *fairQueue {
+	return &fairQueue{
+		weights: weights,
+		queues:  make(map[string][]*task),
+	}
+}
+
+func (q *fairQueue) push(t *task) {
+	if _, ok := q.queues[t.consumerID]; !ok {
+		q.order = append(q.order, t.consumerID)
+	}
+	q.queues[t.consumerID] = append(q.queues[t.consumerID], t)
+	q.size++
+}
+
+func (q *fairQueue) next(canStart func(t *task) bool) *task {
+	// Keep the turn of the current consumer if no task can start,
+	// e.g. because all slots are in use.
+	cursor, credit, granted := q.cursor, q.credit, q.granted
+	// Visit each consumer at most once after the current one.
+	for visits := 0; len(q.order) > 0 && visits <= len(q.order); visits++ {
+		if q.cursor >= len(q.order) {
+			q.cursor = 0
+		}
+		consumerID := q.order[q.cursor]
+		if !q.granted {
+			q.credit = q.weight(consumerID)
+			q.granted = true
+		}
+		t := q.queues[consumerID][0]
+		if q.credit > 0 && canStart(t) {
+			q.credit--
+			q.remove(q.cursor, 0)
+			return t
+		}
+		q.cursor++
+		q.granted = false
+	}
+	q.cursor, q.credit, q.granted = cursor, credit, granted
+	return nil
+}
+
+func (q *fairQueue) dropOldest() *task {
+	oldest := 0
+	for i, consumerID := range q.order {
+		if q.queues[consumerID][0].seq < q.queues[q.order[oldest]][0].seq {
+			oldest = i
+		}
+	}
+	t := q.queues[q.order[oldest]][0]
+	q.remove(oldest, 0)
+	return t
+}
+
+func (q *fairQueue) withdraw(t *task) bool {
+	for pos, consumerID := range q.order {
+		if consumerID != t.consumerID {
+			continue
+		}
+		for i, qt := range q.queues[consumerID] {
+			if qt == t {
+				q.remove(pos, i)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (q *fairQueue) len() int {
+	return q.size
+}
+
+// weight returns the weight of a consumer.
+func (q *fairQueue) weight(consumerID string) int {
+	if weight, ok := q.weights[consumerID]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// remove removes the task i of the consumer at the given position
+// of the order. Consumers without tasks leave the order.
+func (q *fairQueue) remove(pos, i int) {
+	consumerID := q.order[pos]
+	tasks := q.queues[consumerID]
+	tasks = append(tasks[:i:i], tasks[i+1:]...)
+	q.size--
+	if len(tasks) > 0 {
+		q.queues[consumerID] = tasks
+		return
+	}
+	delete(q.queues, consumerID)
+	q.order = append(q.order[:pos], q.order[pos+1:]...)
+	switch {
+	case pos < q.cursor:
+		q.cursor--
+	case pos == q.cursor:
+		q.granted = false
+	}
+}
@@ -0,0 +1,240 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestPoolMaxConcurrency validates the limitation of concurrently
+// executed services globally and per consumer.
+func TestPoolMaxConcurrency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithPool(services.PoolConfig{
+		MaxConcurrency: 3,
+		MaxPerConsumer: 2,
+	}))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var running, maxRunning int
+	perConsumer := map[string]int{}
+	maxPerConsumer := 0
+	newSvc := func(consumerID, svcID string) services.Service {
+		return newCtxService(svcID, func(ctx context.Context) error {
+			mu.Lock()
+			running++
+			perConsumer[consumerID]++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			if perConsumer[consumerID] > maxPerConsumer {
+				maxPerConsumer = perConsumer[consumerID]
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			perConsumer[consumerID]--
+			mu.Unlock()
+			return nil
+		}, &wg)
+	}
+	for _, consumerID := range []string{"foo", "bar"} {
+		for i := 0; i < 5; i++ {
+			p.Book(consumerID, newSvc(consumerID, fmt.Sprintf("svc-%d", i)))
+		}
+	}
+
+	wg.Add(10)
+	sfoo := mustSpawn(t, p, "foo")
+	sbar := mustSpawn(t, p, "bar")
+	if err := sfoo.Err(); err != nil {
+		t.Fatalf("spawning foo failed: %v", err)
+	}
+	if err := sbar.Err(); err != nil {
+		t.Fatalf("spawning bar failed: %v", err)
+	}
+
+	if maxRunning != 3 {
+		t.Fatalf("invalid maximum of running services, expect 3: %d", maxRunning)
+	}
+	if maxPerConsumer != 2 {
+		t.Fatalf("invalid maximum of running services per consumer, expect 2: %d", maxPerConsumer)
+	}
+}
+
+// TestPoolRejection validates the rejection policies in case of
+// a full queue.
+func TestPoolRejection(t *testing.T) {
+	tests := []struct {
+		policy   services.RejectionPolicy
+		rejected string
+	}{
+		{services.RejectNew, "c"},
+		{services.DropOldest, "b"},
+	}
+	for _, test := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := services.StartProvider(ctx, services.WithPool(services.PoolConfig{
+			MaxConcurrency: 1,
+			QueueLength:    1,
+			Rejection:      test.policy,
+		}))
+		var wg sync.WaitGroup
+		releaseC := make(chan struct{})
+		blocking := func(ctx context.Context) error {
+			<-releaseC
+			return nil
+		}
+		p.Book("foo", newCtxService("a", blocking, &wg))
+		p.Book("bar", newCtxService("b", blocking, &wg))
+		p.Book("baz", newCtxService("c", blocking, &wg))
+
+		wg.Add(3)
+		sa := mustSpawn(t, p, "foo")
+		time.Sleep(10 * time.Millisecond)
+		sb := mustSpawn(t, p, "bar")
+		time.Sleep(10 * time.Millisecond)
+		sc := mustSpawn(t, p, "baz")
+		time.Sleep(10 * time.Millisecond)
+		close(releaseC)
+
+		for id, s := range map[string]*services.Spawning{"a": sa, "b": sb, "c": sc} {
+			s.Wait()
+			result, _ := s.Result(id)
+			if id == test.rejected {
+				if result.Err != services.ErrPoolExhausted {
+					t.Fatalf("policy %d: %s has not been rejected: %v", test.policy, id, result.Err)
+				}
+				wg.Done()
+			} else if result.Err != nil {
+				t.Fatalf("policy %d: %s failed: %v", test.policy, id, result.Err)
+			}
+		}
+		wg.Wait()
+		cancel()
+	}
+}
+
+// TestPoolFairScheduling validates the weighted round-robin start
+// of waiting executions across consumers.
+func TestPoolFairScheduling(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      services.PoolConfig
+		expected string
+	}{
+		{
+			name:     "fifo",
+			cfg:      services.PoolConfig{MaxConcurrency: 1},
+			expected: "hhhhhhll",
+		}, {
+			name:     "fair",
+			cfg:      services.PoolConfig{MaxConcurrency: 1, Fair: true},
+			expected: "hlhlhhhh",
+		}, {
+			name: "weighted",
+			cfg: services.PoolConfig{
+				MaxConcurrency: 1,
+				Fair:           true,
+				Weights:        map[string]int{"h": 2},
+			},
+			expected: "hhlhhlhh",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			p := services.StartProvider(ctx, services.WithPool(test.cfg))
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			var started string
+			releaseC := make(chan struct{})
+			blocker := newCtxService("block", func(ctx context.Context) error {
+				<-releaseC
+				return nil
+			}, &wg)
+			p.Book("b", blocker)
+			for consumerID, svcCnt := range map[string]int{"h": 6, "l": 2} {
+				consumerID := consumerID
+				for i := 0; i < svcCnt; i++ {
+					p.Book(consumerID, newCtxService(fmt.Sprintf("svc-%d", i), func(ctx context.Context) error {
+						mu.Lock()
+						started += consumerID
+						mu.Unlock()
+						return nil
+					}, &wg))
+				}
+			}
+
+			wg.Add(9)
+			mustSpawn(t, p, "b")
+			time.Sleep(10 * time.Millisecond)
+			mustSpawn(t, p, "h")
+			time.Sleep(10 * time.Millisecond)
+			mustSpawn(t, p, "l")
+			time.Sleep(10 * time.Millisecond)
+			close(releaseC)
+			wg.Wait()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if started != test.expected {
+				t.Errorf("started %q, expect %q", started, test.expected)
+			}
+		})
+	}
+}
+
+// TestPoolCancelQueued validates that cancelled executions leave
+// the queue and never run.
+func TestPoolCancelQueued(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithPool(services.PoolConfig{
+		MaxConcurrency: 1,
+		QueueLength:    1,
+	}))
+	blocker := newBlockingService("block")
+	cancelled := newBlockingService("cancelled")
+	p.Book("a", blocker)
+	p.Book("b", cancelled)
+	p.Book("c", echoService{id: "echo"})
+
+	sa := mustSpawn(t, p, "a")
+	time.Sleep(10 * time.Millisecond)
+	bCtx, bCancel := context.WithCancel(context.Background())
+	sb := mustSpawnContext(t, p, bCtx, "b")
+	time.Sleep(10 * time.Millisecond)
+	bCancel()
+	sb.Wait()
+	if result, _ := sb.Result("cancelled"); !errors.Is(result.Err, context.Canceled) {
+		t.Fatalf("expected cancelled execution, got %v", result.Err)
+	}
+
+	sc := mustSpawn(t, p, "c")
+	time.Sleep(10 * time.Millisecond)
+	close(blocker.releaseC)
+	sa.Wait()
+	sc.Wait()
+	if err := sc.Err(); err != nil {
+		t.Fatalf("queued execution failed: %v", err)
+	}
+	if runs := cancelled.runs(); runs != 0 {
+		t.Fatalf("cancelled service ran %d times", runs)
+	}
+}
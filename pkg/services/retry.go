@@ -0,0 +1,166 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryableError is the marker interface for errors whose failed
+// service execution may be retried. Errors don't need to depend on
+// this package, having the method is enough.
+type RetryableError interface {
+	error
+
+	// Retryable returns true if the execution may be retried.
+	Retryable() bool
+}
+
+// retryableError marks any error as retryable.
+type retryableError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the marked error.
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// Retryable implements RetryableError.
+func (e *retryableError) Retryable() bool {
+	return true
+}
+
+// Retryable marks an error as retryable. A nil error stays nil.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{
+		err: err,
+	}
+}
+
+// IsRetryable checks if the error or one of the errors it wraps is
+// marked as retryable.
+func IsRetryable(err error) bool {
+	var rerr RetryableError
+	if errors.As(err, &rerr) {
+		return rerr.Retryable()
+	}
+	return false
+}
+
+// RetryPolicy defines if and how failed service executions are
+// retried. Only errors marked as retryable lead to a retry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of executions including
+	// the first one.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff limits the delay between retries. Zero means
+	// no limit.
+	MaxBackoff time.Duration
+
+	// Multiplier increases the delay after each retry. Values
+	// below 1 are handled as 2.
+	Multiplier float64
+
+	// Jitter randomly reduces each delay by up to this fraction,
+	// a value between 0 and 1.
+	Jitter float64
+}
+
+// Backoff returns the delay before the given retry, starting
+// with 1 for the first one.
+func (rp RetryPolicy) Backoff(retry int) time.Duration {
+	multiplier := rp.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	backoff := float64(rp.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if rp.MaxBackoff > 0 && backoff > float64(rp.MaxBackoff) {
+		backoff = float64(rp.MaxBackoff)
+	}
+	if rp.Jitter > 0 {
+		backoff -= backoff * math.Min(rp.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// Retrier is implemented by services defining their own
+// RetryPolicy.
+type Retrier interface {
+	// RetryPolicy returns the RetryPolicy of the Service.
+	RetryPolicy() RetryPolicy
+}
+
+// retryService decorates a Service with a RetryPolicy.
+type retryService struct {
+	wrapper
+	policy RetryPolicy
+}
+
+// WithRetryPolicy decorates a Service with a RetryPolicy, e.g.
+// to use different policies for different bookings.
+func WithRetryPolicy(svc Service, policy RetryPolicy) Service {
+	return &retryService{
+		wrapper: wrapper{svc},
+		policy:  policy,
+	}
+}
+
+// RetryPolicy implements Retrier.
+func (rs *retryService) RetryPolicy() RetryPolicy {
+	return rs.policy
+}
+
+// retryPolicyOf returns the RetryPolicy of a Service. It may be
+// implemented by the Service itself or one of its decorators.
+func retryPolicyOf(svc Service) RetryPolicy {
+	var policy RetryPolicy
+	lookup(svc, func(s Service) bool {
+		if r, ok := s.(Retrier); ok {
+			policy = r.RetryPolicy()
+			return true
+		}
+		return false
+	})
+	return policy
+}
+
+// doRetrying executes the Service and retries it according to its
+// RetryPolicy. Each attempt is counted. The error of the last
+// execution is returned.
+func doRetrying(ctx context.Context, svc Service, attempts *int32) error {
+	policy := retryPolicyOf(svc)
+	countAttempt(attempts)
+	err := do(ctx, svc)
+	for tried := 1; err != nil && tried < policy.MaxAttempts && IsRetryable(err); tried++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(policy.Backoff(tried)):
+		}
+		countAttempt(attempts)
+		err = do(ctx, svc)
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestRetryable validates the marking of errors as retryable.
+func TestRetryable(t *testing.T) {
+	err := errors.New("ouch")
+	if services.IsRetryable(err) {
+		t.Fatalf("unmarked error is retryable")
+	}
+	if services.Retryable(nil) != nil {
+		t.Fatalf("marked nil error is not nil")
+	}
+	rerr := services.Retryable(err)
+	if !services.IsRetryable(rerr) {
+		t.Fatalf("marked error is not retryable")
+	}
+	if !errors.Is(rerr, err) {
+		t.Fatalf("marked error does not wrap error")
+	}
+	if !services.IsRetryable(fmt.Errorf("wrapped: %w", rerr)) {
+		t.Fatalf("wrapped marked error is not retryable")
+	}
+}
+
+// TestRetryPolicyBackoff validates the exponential backoff of
+// retry policies.
+func TestRetryPolicyBackoff(t *testing.T) {
+	rp := services.RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	}
+	for retry, expected := range map[int]time.Duration{
+		1: 10 * time.Millisecond,
+		2: 20 * time.Millisecond,
+		3: 40 * time.Millisecond,
+		4: 50 * time.Millisecond,
+	} {
+		if backoff := rp.Backoff(retry); backoff != expected {
+			t.Fatalf("retry %d has wrong backoff: %v", retry, backoff)
+		}
+	}
+
+	rp.Multiplier = 3
+	rp.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		backoff := rp.Backoff(2)
+		if backoff < 15*time.Millisecond || backoff > 30*time.Millisecond {
+			t.Fatalf("backoff with jitter is out of range: %v", backoff)
+		}
+	}
+}
+
+// TestSpawnRetrying validates the retrying of failed services.
+func TestSpawnRetrying(t *testing.T) {
+	var wg sync.WaitGroup
+	policy := services.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}
+	failing := func(failures int, err error) func(ctx context.Context) error {
+		var mu sync.Mutex
+		calls := 0
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			if calls <= failures {
+				wg.Add(1)
+				return err
+			}
+			return nil
+		}
+	}
+	svcs := services.Services{
+		"a": services.WithRetryPolicy(newCtxService("a", failing(2, services.Retryable(errors.New("a"))), &wg), policy),
+		"b": services.WithRetryPolicy(newCtxService("b", failing(5, services.Retryable(errors.New("b"))), &wg), policy),
+		"c": services.WithRetryPolicy(newCtxService("c", failing(1, errors.New("c")), &wg), policy),
+		"d": newCtxService("d", failing(1, services.Retryable(errors.New("d"))), &wg),
+	}
+
+	wg.Add(4)
+	results := svcs.Spawn().Wait()
+	tests := []struct {
+		attempts int
+		failed   bool
+	}{
+		{3, false},
+		{3, true},
+		{1, true},
+		{1, true},
+	}
+	for i, test := range tests {
+		result := results[i]
+		if result.Attempts != test.attempts {
+			t.Fatalf("%s has wrong number of attempts: %d", result.ServiceID, result.Attempts)
+		}
+		if (result.Err != nil) != test.failed {
+			t.Fatalf("%s has wrong error: %v", result.ServiceID, result.Err)
+		}
+	}
+}
+
+// TestSpawnRetryingCancel validates the end of retrying when the
+// context is cancelled.
+func TestSpawnRetryingCancel(t *testing.T) {
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := services.RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: time.Second,
+	}
+	svcs := services.Services{
+		"a": services.WithRetryPolicy(newCtxService("a", func(ctx context.Context) error {
+			return services.Retryable(errors.New("a"))
+		}, &wg), policy),
+	}
+
+	wg.Add(1)
+	s := svcs.SpawnContext(ctx)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case <-s.Done():
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("retrying not cancelled")
+	}
+	if result, _ := s.Result("a"); result.Attempts != 1 {
+		t.Fatalf("a has wrong number of attempts: %d", result.Attempts)
+	}
+}
@@ -0,0 +1,361 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Spawner defines the spawning of the booked services of a
+// consumer. It is implemented by the Provider.
+type Spawner interface {
+	// Spawn runs the booked services of a consumer.
+	Spawn(consumerID string) (*Spawning, error)
+}
+
+// SpawnerFunc allows to use a simple function as Spawner.
+type SpawnerFunc func(consumerID string) (*Spawning, error)
+
+// Spawn implements Spawner.
+func (sf SpawnerFunc) Spawn(consumerID string) (*Spawning, error) {
+	return sf(consumerID)
+}
+
+// Schedule defines when the bookings of a consumer are spawned.
+// Either Interval or Cron has to be set.
+type Schedule struct {
+	// Interval spawns the bookings in a fixed interval.
+	Interval time.Duration
+
+	// Cron spawns the bookings based on a cron expression with
+	// the five fields minute, hour, day of month, month, and day
+	// of week.
+	Cron string
+
+	// Location is the time zone the cron expression is evaluated
+	// in. Default is the local time zone.
+	Location *time.Location
+
+	// Jitter delays each spawning randomly up to this duration.
+	Jitter time.Duration
+}
+
+// Next returns the next run time of the Schedule after the given
+// time, without jitter.
+func (sch Schedule) Next(after time.Time) (time.Time, error) {
+	next, err := sch.nexter()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return next(after), nil
+}
+
+// nexter validates the Schedule and returns the function calculating
+// its next run time.
+func (sch Schedule) nexter() (func(time.Time) time.Time, error) {
+	switch {
+	case sch.Interval > 0 && sch.Cron != "":
+		return nil, errors.New("schedule must not have interval and cron expression")
+	case sch.Interval > 0:
+		return func(after time.Time) time.Time {
+			return after.Add(sch.Interval)
+		}, nil
+	case sch.Cron != "":
+		ce, err := parseCron(sch.Cron, sch.Location)
+		if err != nil {
+			return nil, err
+		}
+		return ce.next, nil
+	default:
+		return nil, errors.New("schedule needs interval or cron expression")
+	}
+}
+
+// ErrSchedulerStopped is returned by a Scheduler whose context
+// is done.
+var ErrSchedulerStopped = errors.New("scheduler is stopped")
+
+// ScheduleInfo describes the schedule of a consumer. The Last fields
+// contain the outcome of the latest spawning, LastErr is set if the
+// Spawner rejected it.
+type ScheduleInfo struct {
+	ConsumerID  string
+	Schedule    Schedule
+	Next        time.Time
+	Paused      bool
+	LastRun     time.Time
+	LastSpawnID string
+	LastErr     error
+}
+
+// scheduled is the state of the schedule of one consumer.
+type scheduled struct {
+	schedule Schedule
+	next     func(time.Time) time.Time
+	planned  time.Time
+	fireAt   time.Time
+	paused   bool
+	lastRun  time.Time
+	lastID   string
+	lastErr  error
+}
+
+// plan sets the next run time after the given time.
+func (sd *scheduled) plan(after time.Time) {
+	sd.planned = sd.next(after)
+	sd.fireAt = sd.planned
+	if sd.schedule.Jitter > 0 {
+		sd.fireAt = sd.fireAt.Add(time.Duration(rand.Int63n(int64(sd.schedule.Jitter))))
+	}
+}
+
+// Scheduler spawns the bookings of consumers automatically based
+// on their Schedules.
+type Scheduler struct {
+	ctx       context.Context
+	actionC   chan func()
+	spawner   Spawner
+	schedules map[string]*scheduled
+	stoppedC  chan struct{}
+}
+
+// StartScheduler creates a Scheduler running as goroutine. It uses
+// the Spawner, typically a Provider, to spawn the bookings.
+func StartScheduler(ctx context.Context, spawner Spawner) *Scheduler {
+	s := &Scheduler{
+		ctx:       ctx,
+		actionC:   make(chan func()),
+		spawner:   spawner,
+		schedules: make(map[string]*scheduled),
+		stoppedC:  make(chan struct{}),
+	}
+	go s.backend()
+	return s
+}
+
+// Schedule sets the Schedule for the bookings of a consumer. An
+// existing one is replaced.
+func (s *Scheduler) Schedule(consumerID string, sch Schedule) error {
+	next, err := sch.nexter()
+	if err != nil {
+		return fmt.Errorf("cannot schedule consumer %q: %v", consumerID, err)
+	}
+	return s.doSync(func() {
+		sd := &scheduled{
+			schedule: sch,
+			next:     next,
+		}
+		sd.plan(time.Now())
+		s.schedules[consumerID] = sd
+	})
+}
+
+// Unschedule removes the Schedule of a consumer.
+func (s *Scheduler) Unschedule(consumerID string) error {
+	return s.doSync(func() {
+		delete(s.schedules, consumerID)
+	})
+}
+
+// Pause stops the spawning of the bookings of a consumer until
+// it is resumed.
+func (s *Scheduler) Pause(consumerID string) error {
+	var err error
+	if serr := s.doSync(func() {
+		sd, ok := s.schedules[consumerID]
+		if !ok {
+			err = fmt.Errorf("consumer %q is not scheduled", consumerID)
+			return
+		}
+		sd.paused = true
+	}); serr != nil {
+		return serr
+	}
+	return err
+}
+
+// Resume continues the spawning of the bookings of a consumer
+// with the next run time after now.
+func (s *Scheduler) Resume(consumerID string) error {
+	var err error
+	if serr := s.doSync(func() {
+		sd, ok := s.schedules[consumerID]
+		if !ok {
+			err = fmt.Errorf("consumer %q is not scheduled", consumerID)
+			return
+		}
+		if sd.paused {
+			sd.paused = false
+			sd.plan(time.Now())
+		}
+	}); serr != nil {
+		return serr
+	}
+	return err
+}
+
+// NextRuns returns the next n run times of the bookings of a
+// consumer, without jitter.
+func (s *Scheduler) NextRuns(consumerID string, n int) ([]time.Time, error) {
+	var runs []time.Time
+	var err error
+	if serr := s.doSync(func() {
+		sd, ok := s.schedules[consumerID]
+		if !ok {
+			err = fmt.Errorf("consumer %q is not scheduled", consumerID)
+			return
+		}
+		run := sd.planned
+		for i := 0; i < n && !run.IsZero(); i++ {
+			runs = append(runs, run)
+			run = sd.next(run)
+		}
+	}); serr != nil {
+		return nil, serr
+	}
+	return runs, err
+}
+
+// Schedules returns information about all Schedules ordered by
+// their next run time. It's empty if the Scheduler is stopped.
+func (s *Scheduler) Schedules() []ScheduleInfo {
+	var infos []ScheduleInfo
+	s.doSync(func() {
+		for consumerID, sd := range s.schedules {
+			infos = append(infos, ScheduleInfo{
+				ConsumerID:  consumerID,
+				Schedule:    sd.schedule,
+				Next:        sd.planned,
+				Paused:      sd.paused,
+				LastRun:     sd.lastRun,
+				LastSpawnID: sd.lastID,
+				LastErr:     sd.lastErr,
+			})
+		}
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Next.Equal(infos[j].Next) {
+			return infos[i].ConsumerID < infos[j].ConsumerID
+		}
+		return infos[i].Next.Before(infos[j].Next)
+	})
+	return infos
+}
+
+// doSync sends an action for execution to the backend and waits
+// until its done. ErrSchedulerStopped is returned if the backend
+// is stopped.
+func (s *Scheduler) doSync(action func()) error {
+	doneC := make(chan struct{})
+
+	select {
+	case s.actionC <- func() {
+		action()
+		close(doneC)
+	}:
+	case <-s.stoppedC:
+		return ErrSchedulerStopped
+	}
+
+	<-doneC
+	return nil
+}
+
+// doAsync sends an action for execution to the backend without
+// waiting. It's dropped if the backend is stopped.
+func (s *Scheduler) doAsync(action func()) {
+	select {
+	case s.actionC <- action:
+	case <-s.stoppedC:
+	}
+}
+
+// backend is the goroutine of the Scheduler.
+func (s *Scheduler) backend() {
+	defer close(s.stoppedC)
+	for {
+		var timerC <-chan time.Time
+		timer, ok := s.nextTimer()
+		if ok {
+			timerC = timer.C
+		}
+		select {
+		case <-s.ctx.Done():
+			if ok {
+				timer.Stop()
+			}
+			return
+		case action := <-s.actionC:
+			action()
+		case now := <-timerC:
+			s.spawnDue(now)
+		}
+		if ok {
+			timer.Stop()
+		}
+	}
+}
+
+// nextTimer returns a timer firing at the earliest run time of
+// all active schedules. The flag is false if there is none.
+func (s *Scheduler) nextTimer() (*time.Timer, bool) {
+	var earliest time.Time
+	for _, sd := range s.schedules {
+		if sd.paused || sd.fireAt.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || sd.fireAt.Before(earliest) {
+			earliest = sd.fireAt
+		}
+	}
+	if earliest.IsZero() {
+		return nil, false
+	}
+	return time.NewTimer(time.Until(earliest)), true
+}
+
+// spawnDue spawns the bookings of all consumers whose run time has
+// been reached and plans their next runs.
+func (s *Scheduler) spawnDue(now time.Time) {
+	for consumerID, sd := range s.schedules {
+		if sd.paused || sd.fireAt.IsZero() || sd.fireAt.After(now) {
+			continue
+		}
+		// Spawn in own goroutine, the Spawner may block.
+		go s.spawn(consumerID, sd, now)
+		// Plan based on the last run time to avoid drifting,
+		// but skip missed runs.
+		after := sd.planned
+		if sd.next(after).Before(now) {
+			after = now
+		}
+		sd.plan(after)
+	}
+}
+
+// spawn lets the Spawner spawn the bookings of a consumer and records
+// the outcome in its schedule, as long as it has not been replaced.
+func (s *Scheduler) spawn(consumerID string, sd *scheduled, now time.Time) {
+	sp, err := s.spawner.Spawn(consumerID)
+	s.doAsync(func() {
+		if s.schedules[consumerID] != sd {
+			return
+		}
+		sd.lastRun = now
+		sd.lastID = ""
+		if sp != nil {
+			sd.lastID = sp.ID()
+		}
+		sd.lastErr = err
+	})
+}
@@ -0,0 +1,209 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestScheduleNext validates the calculation of the next run
+// times of interval and cron schedules.
+func TestScheduleNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	after := time.Date(2021, time.March, 5, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		schedule services.Schedule
+		next     time.Time
+	}{
+		{
+			services.Schedule{Interval: 90 * time.Second},
+			time.Date(2021, time.March, 5, 10, 19, 0, 0, time.UTC),
+		}, {
+			services.Schedule{Cron: "*/15 * * * *", Location: time.UTC},
+			time.Date(2021, time.March, 5, 10, 30, 0, 0, time.UTC),
+		}, {
+			services.Schedule{Cron: "0 9 * * 1-5", Location: time.UTC},
+			time.Date(2021, time.March, 8, 9, 0, 0, 0, time.UTC),
+		}, {
+			services.Schedule{Cron: "30 2 1,15 * *", Location: time.UTC},
+			time.Date(2021, time.March, 15, 2, 30, 0, 0, time.UTC),
+		}, {
+			services.Schedule{Cron: "0 0 29 2 *", Location: time.UTC},
+			time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		}, {
+			services.Schedule{Cron: "0 12 * * *", Location: berlin},
+			time.Date(2021, time.March, 5, 11, 0, 0, 0, time.UTC),
+		},
+	}
+	for i, test := range tests {
+		next, err := test.schedule.Next(after)
+		if err != nil {
+			t.Fatalf("test %d returned error: %v", i, err)
+		}
+		if !next.Equal(test.next) {
+			t.Fatalf("test %d has wrong next time: %v", i, next)
+		}
+	}
+
+	invalids := []services.Schedule{
+		{},
+		{Interval: time.Second, Cron: "* * * * *"},
+		{Cron: "* * * *"},
+		{Cron: "60 * * * *"},
+		{Cron: "* * * * mon"},
+		{Cron: "5-1 * * * *"},
+	}
+	for i, invalid := range invalids {
+		if _, err := invalid.Next(after); err == nil {
+			t.Fatalf("invalid schedule %d returned no error", i)
+		}
+	}
+}
+
+// TestSchedulerSpawn validates the automatic spawning as well
+// as pausing and resuming of schedules.
+func TestSchedulerSpawn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	spawned := map[string]int{}
+	spawner := services.SpawnerFunc(func(consumerID string) (*services.Spawning, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		spawned[consumerID]++
+		return nil, nil
+	})
+	count := func(consumerID string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return spawned[consumerID]
+	}
+	s := services.StartScheduler(ctx, spawner)
+
+	if err := s.Schedule("foo", services.Schedule{Interval: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("scheduling foo failed: %v", err)
+	}
+	if err := s.Schedule("bar", services.Schedule{Cron: "0 0 1 1 *"}); err != nil {
+		t.Fatalf("scheduling bar failed: %v", err)
+	}
+	if err := s.Schedule("baz", services.Schedule{Cron: "invalid"}); err == nil {
+		t.Fatalf("scheduling baz with invalid cron expression did not fail")
+	}
+
+	infos := s.Schedules()
+	if len(infos) != 2 || infos[0].ConsumerID != "foo" || infos[1].ConsumerID != "bar" {
+		t.Fatalf("invalid schedules: %v", infos)
+	}
+	runs, err := s.NextRuns("foo", 3)
+	if err != nil {
+		t.Fatalf("retrieving next runs failed: %v", err)
+	}
+	if len(runs) != 3 || runs[1].Sub(runs[0]) != 20*time.Millisecond {
+		t.Fatalf("invalid next runs: %v", runs)
+	}
+
+	time.Sleep(110 * time.Millisecond)
+	if n := count("foo"); n < 3 {
+		t.Fatalf("foo has been spawned too seldom: %d", n)
+	}
+	if n := count("bar"); n != 0 {
+		t.Fatalf("bar has been spawned: %d", n)
+	}
+
+	if err := s.Pause("foo"); err != nil {
+		t.Fatalf("pausing foo failed: %v", err)
+	}
+	// Let already started spawns finish.
+	time.Sleep(10 * time.Millisecond)
+	paused := count("foo")
+	time.Sleep(60 * time.Millisecond)
+	if n := count("foo"); n != paused {
+		t.Fatalf("paused foo has been spawned: %d", n-paused)
+	}
+	if err := s.Resume("foo"); err != nil {
+		t.Fatalf("resuming foo failed: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if n := count("foo"); n == paused {
+		t.Fatalf("resumed foo has not been spawned")
+	}
+	if err := s.Pause("baz"); err == nil {
+		t.Fatalf("pausing unscheduled baz did not fail")
+	}
+}
+
+// TestSchedulerLastRun verifies that the outcome of the latest
+// spawning is reported in the ScheduleInfo.
+func TestSchedulerLastRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	spawner := services.SpawnerFunc(func(consumerID string) (*services.Spawning, error) {
+		return nil, services.ErrQuotaExceeded
+	})
+	s := services.StartScheduler(ctx, spawner)
+
+	if err := s.Schedule("foo", services.Schedule{Interval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("scheduling foo failed: %v", err)
+	}
+	infos := s.Schedules()
+	if len(infos) != 1 || !infos[0].LastRun.IsZero() || infos[0].LastErr != nil {
+		t.Fatalf("invalid schedules before run: %v", infos)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		infos = s.Schedules()
+		if len(infos) == 1 && !infos[0].LastRun.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("foo has not been spawned")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !errors.Is(infos[0].LastErr, services.ErrQuotaExceeded) {
+		t.Fatalf("invalid last error: %v", infos[0].LastErr)
+	}
+}
+
+// TestSchedulerStopped verifies that a Scheduler whose context is
+// done doesn't block its callers.
+func TestSchedulerStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := services.StartScheduler(ctx, services.SpawnerFunc(func(consumerID string) (*services.Spawning, error) {
+		return nil, nil
+	}))
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := s.Schedule("foo", services.Schedule{Interval: time.Minute})
+		if errors.Is(err, services.ErrSchedulerStopped) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("scheduling on stopped scheduler did not fail: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := s.Unschedule("foo"); !errors.Is(err, services.ErrSchedulerStopped) {
+		t.Fatalf("unscheduling on stopped scheduler did not fail: %v", err)
+	}
+	if infos := s.Schedules(); len(infos) != 0 {
+		t.Fatalf("stopped scheduler returned schedules: %v", infos)
+	}
+}
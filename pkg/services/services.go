@@ -9,7 +9,14 @@ package services
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/themue/samples/pkg/consumers"
 )
 
 // Service defines a service component a User can book
@@ -22,124 +29,911 @@ type Service interface {
 	Do() error
 }
 
-// Services is a collection of services able to be spawned.
+// ContextService is a Service which additionally can be executed
+// with a context. When spawned its DoContext is preferred over Do,
+// so that cancellation, deadlines, and request-scoped values reach
+// the Service.
+type ContextService interface {
+	Service
+
+	// DoContext executes the Service with the given context.
+	DoContext(ctx context.Context) error
+}
+
+// do executes a Service. DoContext is used if the Service implements
+// ContextService, otherwise Do is called in case the context is not
+// yet done.
+func do(ctx context.Context, svc Service) error {
+	if csvc, ok := svc.(ContextService); ok {
+		return csvc.DoContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return svc.Do()
+}
+
+// wrapper is the base for decorators of a Service. It
+// passes all calls to the decorated Service.
+type wrapper struct {
+	svc Service
+}
+
+// ID implements Service.
+func (w wrapper) ID() string {
+	return w.svc.ID()
+}
+
+// Do implements Service.
+func (w wrapper) Do() error {
+	return do(context.Background(), w.svc)
+}
+
+// DoContext implements ContextService.
+func (w wrapper) DoContext(ctx context.Context) error {
+	return do(ctx, w.svc)
+}
+
+// Unwrap returns the decorated Service.
+func (w wrapper) Unwrap() Service {
+	return w.svc
+}
+
+// lookup walks through the Service and the Services decorated by
+// it until found returns true.
+func lookup(svc Service, found func(s Service) bool) {
+	for svc != nil {
+		if found(svc) {
+			return
+		}
+		u, ok := svc.(interface{ Unwrap() Service })
+		if !ok {
+			return
+		}
+		svc = u.Unwrap()
+	}
+}
+
+// Services is a collection of services able to be spawned. They
+// are executed concurrently as far as their dependencies allow.
 type Services map[string]Service
 
-// Spawn executes all services concurrently.
-func (svcs Services) Spawn() {
+// Spawn executes all services concurrently. The returned Spawning
+// allows to wait for the results.
+func (svcs Services) Spawn() *Spawning {
+	return svcs.SpawnContext(context.Background())
+}
+
+// SpawnContext executes all services concurrently using the
+// passed context. The returned Spawning allows to wait for
+// the results.
+func (svcs Services) SpawnContext(ctx context.Context) *Spawning {
+	s := newSpawning(nil)
+	return svcs.spawn(ctx, s, func(ctx context.Context, svcID string, svc Service) Result {
+		s.execute()
+		return run(ctx, svcID, svc)
+	}, func() {})
+}
+
+// selectIDs returns the Services with the given IDs. Unknown IDs
+// lead to an error. Dependencies on not selected Services are
+// dropped, they are treated as done.
+func (svcs Services) selectIDs(svcIDs []string) (Services, error) {
+	selected := make(Services, len(svcIDs))
+	for _, svcID := range svcIDs {
+		svc, ok := svcs[svcID]
+		if !ok {
+			return nil, fmt.Errorf("service %q is not booked", svcID)
+		}
+		selected[svcID] = svc
+	}
+	for svcID, svc := range selected {
+		depIDs := dependenciesOf(svc)
+		if len(depIDs) == 0 {
+			continue
+		}
+		var kept []string
+		for _, depID := range depIDs {
+			if _, ok := selected[depID]; ok {
+				kept = append(kept, depID)
+			}
+		}
+		if len(kept) < len(depIDs) {
+			selected[svcID] = WithDependencies(svc, kept...)
+		}
+	}
+	return selected, nil
+}
+
+// runFunc executes a Service and returns its Result.
+type runFunc func(ctx context.Context, svcID string, svc Service) Result
+
+// spawn executes all services concurrently using the run function
+// and adds the results to the Spawning. Services depending on others
+// are started when those succeeded, otherwise they are skipped. Once
+// all of them are done finally will be called.
+func (svcs Services) spawn(ctx context.Context, s *Spawning, run runFunc, finally func()) *Spawning {
+	svcIDs := make([]string, 0, len(svcs))
+	for id := range svcs {
+		svcIDs = append(svcIDs, id)
+	}
+	s.start(svcIDs)
+	_, err := svcs.Order()
 	go func() {
+		defer s.finish()
+		defer finally()
+		if err != nil {
+			// Dependency cycle, so none can be executed.
+			for id := range svcs {
+				s.add(Result{
+					ServiceID: id,
+					Started:   time.Now(),
+					Err:       err,
+				})
+			}
+			return
+		}
+		var wg sync.WaitGroup
+		doneCs := make(map[string]chan struct{}, len(svcs))
+		for id := range svcs {
+			doneCs[id] = make(chan struct{})
+		}
+		wg.Add(len(svcs))
 		for id, svc := range svcs {
 			// Don't use loop variables directly, they will
 			// change during iteration.
-			go func(doID string, do func() error) {
-				if err := do(); err != nil {
-					log.Printf("execution of service %q failed: %v", doID, err)
+			go func(doID string, doSvc Service) {
+				defer wg.Done()
+				defer close(doneCs[doID])
+				if err := awaitDependencies(s, doneCs, doID, doSvc); err != nil {
+					s.add(Result{
+						ServiceID: doID,
+						Started:   time.Now(),
+						Err:       err,
+					})
+					return
 				}
-			}(id, svc.Do)
+				s.add(run(ctx, doID, doSvc))
+			}(id, svc)
 		}
+		wg.Wait()
 	}()
+	return s
+}
+
+// awaitDependencies waits until all dependencies of the Service are
+// done. A *DependencyError is returned if one of them failed or
+// is unknown.
+func awaitDependencies(s *Spawning, doneCs map[string]chan struct{}, svcID string, svc Service) error {
+	for _, depID := range dependenciesOf(svc) {
+		doneC, ok := doneCs[depID]
+		if !ok {
+			return &DependencyError{
+				ServiceID:    svcID,
+				DependencyID: depID,
+				Err:          ErrUnknownDependency,
+			}
+		}
+		<-doneC
+		if result, _ := s.Result(depID); result.Err != nil {
+			return &DependencyError{
+				ServiceID:    svcID,
+				DependencyID: depID,
+				Err:          result.Err,
+			}
+		}
+	}
+	return nil
+}
+
+// run executes a Service including retries and timeout and returns
+// its Result.
+func run(ctx context.Context, svcID string, svc Service) Result {
+	result, _ := runService(ctx, svcID, svc)
+	return result
+}
+
+// runService executes a Service like run. The returned channel is
+// closed when the execution has really ended, which is later than
+// the Result in case it has been abandoned after a timeout.
+func runService(ctx context.Context, svcID string, svc Service) (Result, <-chan struct{}) {
+	var attempts int32
+	var err error
+	endedC := closedC
+	started := time.Now()
+	if timeout := timeoutOf(svc); timeout > 0 {
+		endedC, err = doTimed(ctx, svcID, svc, timeout, &attempts)
+	} else {
+		err = doRetrying(ctx, svc, &attempts)
+	}
+	return Result{
+		ServiceID: svcID,
+		Started:   started,
+		Duration:  time.Since(started),
+		Attempts:  int(atomic.LoadInt32(&attempts)),
+		Err:       err,
+	}, endedC
 }
 
+// closedC is a closed channel for executions which ended.
+var closedC = func() <-chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
 // Provider manages the Services per consumer. Those
 // can be added and removed as well as spawned. In that
 // case the individual services are executed concurrently.
 type Provider struct {
-	ctx      context.Context
-	actionC  chan func()
-	bookings map[string]Services
+	ctx         context.Context
+	actionC     chan func()
+	bookings    map[string]Services
+	poolCfg     PoolConfig
+	pool        *pool
+	breakers    map[string]*CircuitBreaker
+	timeouts    map[string]time.Duration
+	store       BookingStore
+	catalog     *Catalog
+	specs       map[string]map[string]Spec
+	infos       map[string]map[string]*BookingInfo
+	events      *eventHub
+	limiter     *limiter
+	history     History
+	meter       *Meter
+	reader      ConsumerReader
+	plans       consumers.Plans
+	auth        Authenticator
+	overlaps    *overlapGuard
+	running     map[*Spawning]runningSpawn
+	spawnSeq    uint64
+	spawns      map[string]*Spawning
+	finished    []string
+	retention   int
+	deadLetters *DeadLetterQueue
+	stopping    bool
+	stopOnce    sync.Once
+	stopC       chan struct{}
+	stoppedC    chan struct{}
+}
+
+// Option defines a function configuring a Provider when starting it.
+type Option func(p *Provider)
+
+// WithPool configures the pool executing the services. By default
+// the number of concurrent executions is not limited.
+func WithPool(cfg PoolConfig) Option {
+	return func(p *Provider) {
+		p.poolCfg = cfg
+	}
+}
+
+// WithCircuitBreakers protects the services with the given IDs by
+// circuit breakers. Each service ID gets its own breaker shared by
+// all consumers.
+func WithCircuitBreakers(cfg BreakerConfig, svcIDs ...string) Option {
+	return func(p *Provider) {
+		for _, svcID := range svcIDs {
+			p.breakers[svcID] = NewCircuitBreaker(cfg)
+		}
+	}
+}
+
+// WithTimeouts limits the execution time of the services with the
+// given IDs. Timeouts of individual bookings have precedence.
+func WithTimeouts(timeout time.Duration, svcIDs ...string) Option {
+	return func(p *Provider) {
+		for _, svcID := range svcIDs {
+			p.timeouts[svcID] = timeout
+		}
+	}
+}
+
+// WithBookingStore persists the bookings done by Specs in the store.
+func WithBookingStore(store BookingStore) Option {
+	return func(p *Provider) {
+		p.store = store
+	}
+}
+
+// WithCatalog sets the Catalog used to create the services of Specs
+// when booking or restoring them. Default is the DefaultCatalog.
+func WithCatalog(catalog *Catalog) Option {
+	return func(p *Provider) {
+		p.catalog = catalog
+	}
+}
+
+// WithHistory records each service execution as Run in the History.
+func WithHistory(history History) Option {
+	return func(p *Provider) {
+		p.history = history
+	}
+}
+
+// WithMeter meters each service execution in the Meter.
+func WithMeter(meter *Meter) Option {
+	return func(p *Provider) {
+		p.meter = meter
+	}
 }
 
 // StartProvider creates a Provider running as goroutine.
-func StartProvider(ctx context.Context) *Provider {
+func StartProvider(ctx context.Context, options ...Option) *Provider {
 	p := &Provider{
-		ctx:      ctx,
-		actionC:  make(chan func(), 16),
-		bookings: make(map[string]Services),
+		ctx:       ctx,
+		actionC:   make(chan func(), 16),
+		bookings:  make(map[string]Services),
+		breakers:  make(map[string]*CircuitBreaker),
+		timeouts:  make(map[string]time.Duration),
+		catalog:   DefaultCatalog,
+		specs:     make(map[string]map[string]Spec),
+		infos:     make(map[string]map[string]*BookingInfo),
+		events:    newEventHub(),
+		limiter:   newLimiter(),
+		overlaps:  newOverlapGuard(),
+		running:   make(map[*Spawning]runningSpawn),
+		spawns:    make(map[string]*Spawning),
+		retention: 100,
+		stopC:     make(chan struct{}),
+		stoppedC:  make(chan struct{}),
+	}
+	for _, option := range options {
+		option(p)
 	}
+	p.pool = newPool(p.poolCfg)
 	go p.backend()
 	return p
 }
 
 // Book assigns Services to a consumer, like e.g. a User. The
-// number of booked services is returned.
-func (p *Provider) Book(consumerID string, svcs ...Service) int {
+// number of booked services is returned. Services booked this
+// way are not persisted, they replace persisted ones with the
+// same ID. If the Provider has an Authenticator the booking has
+// to be done by a Session.
+func (p *Provider) Book(consumerID string, svcs ...Service) (int, error) {
+	if err := p.requireSession(); err != nil {
+		return 0, fmt.Errorf("cannot book services for consumer %q: %w", consumerID, err)
+	}
+	plan, err := p.planOf(consumerID)
+	if err != nil {
+		return 0, fmt.Errorf("cannot book services for consumer %q: %w", consumerID, err)
+	}
+	return p.bookServices(consumerID, plan, svcs...)
+}
+
+// bookServices books the Services after checking them against the
+// Plan of the consumer.
+func (p *Provider) bookServices(consumerID string, plan *consumers.Plan, svcs ...Service) (int, error) {
+	svcIDs := make([]string, len(svcs))
+	types := make([]string, len(svcs))
+	for i, svc := range svcs {
+		svcIDs[i] = svc.ID()
+		types[i] = typeOf(svc)
+	}
+	if err := checkTypes(consumerID, plan, svcIDs, types); err != nil {
+		return 0, fmt.Errorf("cannot book services for consumer %q: %w", consumerID, err)
+	}
 	var svcCnt int
-	p.doSync(func() {
-		current, ok := p.bookings[consumerID]
-		if !ok {
-			current = make(Services)
+	var err error
+	if serr := p.doActive(func() {
+		if err = p.checkBookings(consumerID, plan, svcIDs); err != nil {
+			err = fmt.Errorf("cannot book services for consumer %q: %w", consumerID, err)
+			return
 		}
+		// Plain bookings replace persisted ones, so persist first
+		// to keep the bookings unchanged if it fails.
+		specs := p.specsOf(consumerID)
+		persist := false
 		for _, svc := range svcs {
-			current[svc.ID()] = svc
+			if _, ok := specs[svc.ID()]; ok {
+				delete(specs, svc.ID())
+				persist = true
+			}
+		}
+		if persist {
+			if err = p.persist(consumerID, specs); err != nil {
+				return
+			}
+		}
+		svcCnt = p.book(consumerID, svcs...)
+	}); serr != nil {
+		return 0, serr
+	}
+	return svcCnt, err
+}
+
+// BookSpecs creates Services based on their Specs by the Catalog and
+// assigns them to a consumer. If the Provider has a BookingStore the
+// bookings are persisted. The number of booked services is returned.
+// If the Provider has an Authenticator the booking has to be done
+// by a Session.
+func (p *Provider) BookSpecs(consumerID string, specs ...Spec) (int, error) {
+	if err := p.requireSession(); err != nil {
+		return 0, fmt.Errorf("cannot book specs for consumer %q: %w", consumerID, err)
+	}
+	plan, err := p.planOf(consumerID)
+	if err != nil {
+		return 0, fmt.Errorf("cannot book specs for consumer %q: %w", consumerID, err)
+	}
+	return p.bookSpecs(consumerID, plan, specs...)
+}
+
+// bookSpecs books the Specs after checking them against the Plan of
+// the consumer.
+func (p *Provider) bookSpecs(consumerID string, plan *consumers.Plan, specs ...Spec) (int, error) {
+	svcIDs := make([]string, len(specs))
+	types := make([]string, len(specs))
+	for i, spec := range specs {
+		svcIDs[i] = spec.ServiceID
+		types[i] = spec.Type
+	}
+	if err := checkTypes(consumerID, plan, svcIDs, types); err != nil {
+		return 0, fmt.Errorf("cannot book specs for consumer %q: %w", consumerID, err)
+	}
+	svcs := make([]Service, len(specs))
+	for i, spec := range specs {
+		svc, err := p.catalog.Create(spec)
+		if err != nil {
+			return 0, fmt.Errorf("cannot book specs for consumer %q: %v", consumerID, err)
+		}
+		svcs[i] = svc
+	}
+	var svcCnt int
+	var err error
+	if serr := p.doActive(func() {
+		if err = p.checkBookings(consumerID, plan, svcIDs); err != nil {
+			err = fmt.Errorf("cannot book specs for consumer %q: %w", consumerID, err)
+			return
+		}
+		// Persist first to keep the bookings unchanged if it fails.
+		persisted := p.specsOf(consumerID)
+		for _, spec := range specs {
+			persisted[spec.ServiceID] = spec
 		}
-		svcCnt = len(current)
-		p.bookings[consumerID] = current
-	})
-	return svcCnt
+		if err = p.persist(consumerID, persisted); err != nil {
+			return
+		}
+		svcCnt = p.book(consumerID, svcs...)
+	}); serr != nil {
+		return 0, serr
+	}
+	return svcCnt, err
 }
 
 // Unbook drops assignment of a Services to a consumer. The
-// number of booked services is returned.
-func (p *Provider) Unbook(consumerID string, svcIDs ...string) int {
+// number of booked services is returned. If the Provider has an
+// Authenticator the unbooking has to be done by a Session.
+func (p *Provider) Unbook(consumerID string, svcIDs ...string) (int, error) {
+	if err := p.requireSession(); err != nil {
+		return 0, fmt.Errorf("cannot unbook services for consumer %q: %w", consumerID, err)
+	}
+	return p.unbook(consumerID, svcIDs...)
+}
+
+// unbook drops the assignment of Services to a consumer.
+func (p *Provider) unbook(consumerID string, svcIDs ...string) (int, error) {
 	var svcCnt int
-	p.doSync(func() {
+	var err error
+	if serr := p.doActive(func() {
 		current, ok := p.bookings[consumerID]
 		if !ok {
 			return
 		}
+		// Persist first to keep the bookings unchanged if it fails.
+		specs := p.specsOf(consumerID)
+		persist := false
 		for _, svcID := range svcIDs {
-			delete(current, svcID)
+			if _, ok := specs[svcID]; ok {
+				delete(specs, svcID)
+				persist = true
+			}
+		}
+		if persist {
+			if err = p.persist(consumerID, specs); err != nil {
+				return
+			}
+		}
+		for _, svcID := range svcIDs {
+			if _, ok := current[svcID]; ok {
+				delete(current, svcID)
+				delete(p.infos[consumerID], svcID)
+				p.events.emit(Event{
+					Kind:       EventUnbooked,
+					ConsumerID: consumerID,
+					ServiceID:  svcID,
+				})
+			}
 		}
 		if len(current) == 0 {
 			delete(p.bookings, consumerID)
+			delete(p.infos, consumerID)
 		} else {
 			svcCnt = len(current)
 			p.bookings[consumerID] = current
 		}
-	})
-	return svcCnt
+	}); serr != nil {
+		return 0, serr
+	}
+	return svcCnt, err
 }
 
-// Spawn runs the booked services of a consumer concurrently.
-func (p *Provider) Spawn(consumerID string) {
-	p.doAsync(func() {
-		svcs, ok := p.bookings[consumerID]
-		if !ok {
+// Restore books the Specs persisted in the BookingStore, typically
+// at startup. Specs which cannot be created are reported in the
+// returned error while the others are booked.
+func (p *Provider) Restore() error {
+	if p.store == nil {
+		return fmt.Errorf("cannot restore bookings: no booking store")
+	}
+	all, err := p.store.All()
+	if err != nil {
+		return fmt.Errorf("cannot restore bookings: %v", err)
+	}
+	var failed []string
+	for consumerID, specs := range all {
+		var svcs []Service
+		var created []Spec
+		for _, spec := range specs {
+			svc, err := p.catalog.Create(spec)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("consumer %q: %v", consumerID, err))
+				continue
+			}
+			svcs = append(svcs, svc)
+			created = append(created, spec)
+		}
+		if err := p.doActive(func() {
+			p.book(consumerID, svcs...)
+			if p.specs[consumerID] == nil {
+				p.specs[consumerID] = make(map[string]Spec)
+			}
+			for _, spec := range created {
+				p.specs[consumerID][spec.ServiceID] = spec
+			}
+		}); err != nil {
+			return fmt.Errorf("cannot restore bookings: %w", err)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("cannot restore all bookings: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// book assigns Services to a consumer and returns the number of
+// its booked services.
+func (p *Provider) book(consumerID string, svcs ...Service) int {
+	current, ok := p.bookings[consumerID]
+	if !ok {
+		current = make(Services)
+		p.infos[consumerID] = make(map[string]*BookingInfo)
+	}
+	for _, svc := range svcs {
+		current[svc.ID()] = svc
+		p.infos[consumerID][svc.ID()] = &BookingInfo{
+			ConsumerID: consumerID,
+			ServiceID:  svc.ID(),
+			BookedAt:   time.Now(),
+		}
+		p.events.emit(Event{
+			Kind:       EventBooked,
+			ConsumerID: consumerID,
+			ServiceID:  svc.ID(),
+		})
+	}
+	p.bookings[consumerID] = current
+	return len(current)
+}
+
+// specsOf returns a copy of the persisted Specs of a consumer.
+func (p *Provider) specsOf(consumerID string) map[string]Spec {
+	specs := make(map[string]Spec, len(p.specs[consumerID]))
+	for svcID, spec := range p.specs[consumerID] {
+		specs[svcID] = spec
+	}
+	return specs
+}
+
+// persist stores the Specs of a consumer in the BookingStore if the
+// Provider has one. Only if it succeeds they become the persisted
+// Specs of the consumer.
+func (p *Provider) persist(consumerID string, persisted map[string]Spec) error {
+	if p.store != nil {
+		specs := make([]Spec, 0, len(persisted))
+		for _, spec := range persisted {
+			specs = append(specs, spec)
+		}
+		var err error
+		if len(specs) == 0 {
+			err = p.store.Delete(consumerID)
+		} else {
+			sort.Slice(specs, func(i, j int) bool {
+				return specs[i].ServiceID < specs[j].ServiceID
+			})
+			err = p.store.Put(consumerID, specs)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot persist bookings of consumer %q: %v", consumerID, err)
+		}
+	}
+	if len(persisted) == 0 {
+		delete(p.specs, consumerID)
+	} else {
+		p.specs[consumerID] = persisted
+	}
+	return nil
+}
+
+// Spawn runs the booked services of a consumer concurrently. They
+// are cancelled when the context of the Provider is done. The
+// returned Spawning allows to wait for the results. It contains
+// no results if the consumer has no bookings.
+func (p *Provider) Spawn(consumerID string) (*Spawning, error) {
+	return p.SpawnContext(context.Background(), consumerID)
+}
+
+// SpawnContext runs the booked services of a consumer concurrently.
+// They are cancelled when the passed context or the context of the
+// Provider is done. Values of the passed context are handed to the
+// services. The returned Spawning allows to wait for the results.
+// Spawnings exceeding the limits of the consumer are rejected with
+// a *LimitError. If the Provider has an Authenticator the spawning
+// has to be done by a Session.
+func (p *Provider) SpawnContext(ctx context.Context, consumerID string) (*Spawning, error) {
+	if err := p.requireSession(); err != nil {
+		return nil, fmt.Errorf("cannot spawn services of consumer %q: %w", consumerID, err)
+	}
+	return p.spawnContext(ctx, consumerID, nil)
+}
+
+// spawnContext runs the booked services of a consumer concurrently.
+// If service IDs are passed only those are spawned.
+func (p *Provider) spawnContext(ctx context.Context, consumerID string, svcIDs []string) (*Spawning, error) {
+	var s *Spawning
+	var err error
+	if serr := p.doActive(func() {
+		// Spawn a copy, the bookings may change while the
+		// services are running.
+		booked := Services{}
+		for id, svc := range p.bookings[consumerID] {
+			booked[id] = svc
+		}
+		// Each booking has its own metadata, so it identifies the
+		// booking the results belong to.
+		infos := make(map[string]*BookingInfo, len(booked))
+		for id, bi := range p.infos[consumerID] {
+			infos[id] = bi
+		}
+		svcs := booked
+		if svcIDs != nil {
+			if svcs, err = booked.selectIDs(svcIDs); err != nil {
+				return
+			}
+		}
+		if err = p.limiter.allowSpawn(consumerID, len(svcs), time.Now()); err != nil {
 			return
 		}
-		svcs.Spawn()
-	})
+		p.events.emit(Event{
+			Kind:       EventSpawnStarted,
+			ConsumerID: consumerID,
+		})
+		observe := func(result Result) {
+			p.events.emitResult(consumerID, result)
+			p.recordResult(consumerID, infos[result.ServiceID], result)
+			if p.history != nil {
+				// Errors of the History must not disturb the
+				// execution of the services.
+				_ = p.history.Record(newRun(consumerID, result))
+			}
+			if p.meter != nil {
+				p.meter.Record(consumerID, result)
+			}
+			if p.deadLetters != nil {
+				p.deadLetters.capture(consumerID, result)
+			}
+		}
+		spawnCtx, cancel := joinContext(ctx, p.ctx)
+		p.spawnSeq++
+		s = newSpawning(observe)
+		s.id = fmt.Sprintf("spawn-%d", p.spawnSeq)
+		s.consumerID = consumerID
+		p.running[s] = runningSpawn{
+			consumerID: consumerID,
+			cancel:     cancel,
+		}
+		p.spawns[s.id] = s
+		svcs.spawn(spawnCtx, s, p.runner(consumerID, s), func() {
+			cancel()
+			p.doAsync(func() {
+				delete(p.running, s)
+				p.retain(s)
+			})
+		})
+	}); serr != nil {
+		err = serr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot spawn services of consumer %q: %w", consumerID, err)
+	}
+	return s, nil
+}
+
+// Subscribe creates a Subscription for the Events of the Provider.
+// Only the given kinds are received, all if none are passed. The
+// size defines the buffer of the Subscription, Events exceeding
+// it are dropped.
+func (p *Provider) Subscribe(size int, kinds ...EventKind) *Subscription {
+	return p.events.subscribe(size, kinds...)
+}
+
+// Breakers returns the status of all circuit breakers by service ID.
+func (p *Provider) Breakers() map[string]BreakerStatus {
+	statuses := make(map[string]BreakerStatus, len(p.breakers))
+	for svcID, cb := range p.breakers {
+		statuses[svcID] = cb.Status()
+	}
+	return statuses
+}
+
+// Breaker returns the circuit breaker of the service with the
+// given ID, e.g. to reset it.
+func (p *Provider) Breaker(svcID string) (*CircuitBreaker, bool) {
+	cb, ok := p.breakers[svcID]
+	return cb, ok
+}
+
+// runner returns the function executing the services of a consumer
+// inside the pool of the Provider. The Spawning is marked as running
+// when the first execution leaves the pool queue.
+func (p *Provider) runner(consumerID string, s *Spawning) runFunc {
+	return func(ctx context.Context, svcID string, svc Service) Result {
+		if err := p.limiter.allowService(consumerID, svcID, time.Now()); err != nil {
+			return Result{
+				ServiceID: svcID,
+				Started:   time.Now(),
+				Err:       err,
+			}
+		}
+		ctx, release, err := p.overlaps.acquire(ctx, consumerID, svcID)
+		if err != nil {
+			return Result{
+				ServiceID: svcID,
+				Started:   time.Now(),
+				Err:       err,
+			}
+		}
+		if cb, ok := p.breakers[svcID]; ok {
+			svc = WithCircuitBreaker(svc, cb)
+		}
+		if timeout, ok := p.timeouts[svcID]; ok && timeoutOf(svc) == 0 {
+			svc = WithTimeout(svc, timeout)
+		}
+		resultC := make(chan Result, 1)
+		t := &task{
+			consumerID: consumerID,
+			run: func() {
+				s.execute()
+				result, endedC := runService(ctx, svcID, svc)
+				resultC <- result
+				// Keep the pool slot and the overlap state until an
+				// abandoned execution has really ended.
+				<-endedC
+				release()
+			},
+			reject: func(err error) {
+				release()
+				resultC <- Result{
+					ServiceID: svcID,
+					Started:   time.Now(),
+					Err:       err,
+				}
+			},
+		}
+		p.pool.submit(t)
+		select {
+		case result := <-resultC:
+			return result
+		case <-ctx.Done():
+			if p.pool.withdraw(t) {
+				// Still waiting in the queue, so it will never run.
+				release()
+				return Result{
+					ServiceID: svcID,
+					Started:   time.Now(),
+					Err:       ctx.Err(),
+				}
+			}
+			return <-resultC
+		}
+	}
 }
 
 // doSync sends an action for execution to the backend and waits
-// until its done. Right now no handling of timeouts to cover
-// closed channels.
-func (p *Provider) doSync(action func()) {
+// until its done. ErrProviderStopped is returned if the backend
+// is not running anymore.
+func (p *Provider) doSync(action func()) error {
 	doneC := make(chan struct{})
 
-	p.actionC <- func() {
+	select {
+	case p.actionC <- func() {
 		action()
 		close(doneC)
+	}:
+	case <-p.stoppedC:
+		return ErrProviderStopped
 	}
 
 	// Wait.
-	<-doneC
+	select {
+	case <-doneC:
+		return nil
+	case <-p.stoppedC:
+		// Maybe done as last action.
+		select {
+		case <-doneC:
+			return nil
+		default:
+			return ErrProviderStopped
+		}
+	}
+}
+
+// doActive works like doSync but rejects the action with
+// ErrProviderStopped if the Provider is stopping.
+func (p *Provider) doActive(action func()) error {
+	stopping := false
+	if err := p.doSync(func() {
+		if p.stopping {
+			stopping = true
+			return
+		}
+		action()
+	}); err != nil {
+		return err
+	}
+	if stopping {
+		return ErrProviderStopped
+	}
+	return nil
 }
 
-// doAsync sends an action for execution to the backend.
+// doAsync sends an action for execution to the backend. It is
+// dropped if the backend is not running anymore.
 func (p *Provider) doAsync(action func()) {
-	p.actionC <- action
+	select {
+	case p.actionC <- action:
+	case <-p.stoppedC:
+	}
 }
 
-// backend is the goroutine of the Provider.
+// backend is the goroutine of the Provider. The action channel is
+// not closed when ending, executing goroutines may still send.
 func (p *Provider) backend() {
-	defer close(p.actionC)
+	defer close(p.stoppedC)
 	for {
 		select {
 		case <-p.ctx.Done():
 			return
+		case <-p.stopC:
+			return
 		case action := <-p.actionC:
 			action()
 		}
 	}
 }
+
+// joinContext derives a context from ctx which additionally is
+// cancelled when other is done. The returned cancel function has
+// to be called to release the resources.
+func joinContext(ctx, other context.Context) (context.Context, context.CancelFunc) {
+	joinedCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-other.Done():
+			cancel()
+		case <-joinedCtx.Done():
+		}
+	}()
+	return joinedCtx, cancel
+}
@@ -11,6 +11,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/themue/samples/pkg/services"
 )
@@ -63,27 +64,45 @@ func TestProviderBookUnbook(t *testing.T) {
 	svcb := newDummyService("b", func(i int) {}, &wg)
 	svcc := newDummyService("c", func(i int) {}, &wg)
 
-	svcCnt := p.Book("foo", svca, svcb)
+	svcCnt, err := p.Book("foo", svca, svcb)
+	if err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
 	if svcCnt != 2 {
 		t.Fatalf("invalid number of services, expect 2: %d", svcCnt)
 	}
-	svcCnt = p.Book("foo", svcc)
+	svcCnt, err = p.Book("foo", svcc)
+	if err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
 	if svcCnt != 3 {
 		t.Fatalf("invalid number of services, expect 3: %d", svcCnt)
 	}
-	svcCnt = p.Book("foo", svca)
+	svcCnt, err = p.Book("foo", svca)
+	if err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
 	if svcCnt != 3 {
 		t.Fatalf("invalid number of services, expect 3: %d", svcCnt)
 	}
-	svcCnt = p.Unbook("foo", "a", "b")
+	svcCnt, err = p.Unbook("foo", "a", "b")
+	if err != nil {
+		t.Fatalf("unbooking failed: %v", err)
+	}
 	if svcCnt != 1 {
 		t.Fatalf("invalid number of services, expect 1: %d", svcCnt)
 	}
-	svcCnt = p.Unbook("foo", "a", "c")
+	svcCnt, err = p.Unbook("foo", "a", "c")
+	if err != nil {
+		t.Fatalf("unbooking failed: %v", err)
+	}
 	if svcCnt != 0 {
 		t.Fatalf("invalid number of services, expect 0: %d", svcCnt)
 	}
-	svcCnt = p.Unbook("bar", "b")
+	svcCnt, err = p.Unbook("bar", "b")
+	if err != nil {
+		t.Fatalf("unbooking failed: %v", err)
+	}
 	if svcCnt != 0 {
 		t.Fatalf("invalid number of services, expect 0: %d", svcCnt)
 	}
@@ -110,16 +129,19 @@ func TestProviderSpawn(t *testing.T) {
 	svcb := newDummyService("b", cbb, &wg)
 	svcc := newDummyService("c", cbc, &wg)
 
-	svcCnt := p.Book("foo", svca, svcb, svcc)
+	svcCnt, err := p.Book("foo", svca, svcb, svcc)
+	if err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
 	if svcCnt != 3 {
 		t.Fatalf("invalid number of services, expect 3: %d", svcCnt)
 	}
 
 	wg.Add(9)
-	p.Spawn("foo")
-	p.Spawn("foo")
-	p.Spawn("foo")
-	p.Spawn("bar")
+	mustSpawn(t, p, "foo")
+	mustSpawn(t, p, "foo")
+	mustSpawn(t, p, "foo")
+	mustSpawn(t, p, "bar")
 	wg.Wait()
 
 	if dsia != 3 {
@@ -133,6 +155,99 @@ func TestProviderSpawn(t *testing.T) {
 	}
 }
 
+// TestSpawnServicesContext validates that services implementing
+// ContextService retrieve the passed context while simple ones
+// are not executed when the context is already done.
+func TestSpawnServicesContext(t *testing.T) {
+	var wg sync.WaitGroup
+	type ctxKey string
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey("k"), "v"))
+	values := make(chan interface{}, 1)
+	svcs := services.Services{
+		"a": newCtxService("a", func(ctx context.Context) error {
+			values <- ctx.Value(ctxKey("k"))
+			return nil
+		}, &wg),
+	}
+
+	wg.Add(1)
+	svcs.SpawnContext(ctx)
+	wg.Wait()
+
+	if v := <-values; v != "v" {
+		t.Fatalf("context value not passed: %v", v)
+	}
+
+	cancel()
+	var dsia int
+	svcs = services.Services{
+		"a": newDummyService("a", func(i int) { dsia = i }, &wg),
+	}
+	svcs.SpawnContext(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	if dsia != 0 {
+		t.Fatalf("a has been executed with done context: %d", dsia)
+	}
+}
+
+// TestProviderSpawnContext validates the cancellation of booked
+// services by the caller and by the Provider.
+func TestProviderSpawnContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx)
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	svca := newCtxService("a", func(ctx context.Context) error {
+		<-ctx.Done()
+		errs <- ctx.Err()
+		return ctx.Err()
+	}, &wg)
+
+	p.Book("foo", svca)
+
+	// Cancellation by the caller.
+	spawnCtx, spawnCancel := context.WithCancel(context.Background())
+	wg.Add(1)
+	mustSpawnContext(t, p, spawnCtx, "foo")
+	spawnCancel()
+	wg.Wait()
+
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("service not cancelled by caller: %v", err)
+	}
+
+	// Cancellation by the Provider.
+	wg.Add(1)
+	mustSpawn(t, p, "foo")
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("service not cancelled by provider: %v", err)
+	}
+}
+
+// mustSpawn spawns the services of a consumer and fails the
+// test in case of an error.
+func mustSpawn(t *testing.T, p *services.Provider, consumerID string) *services.Spawning {
+	t.Helper()
+	return mustSpawnContext(t, p, context.Background(), consumerID)
+}
+
+// mustSpawnContext spawns the services of a consumer with a context
+// and fails the test in case of an error.
+func mustSpawnContext(t *testing.T, p *services.Provider, ctx context.Context, consumerID string) *services.Spawning {
+	t.Helper()
+	s, err := p.SpawnContext(ctx, consumerID)
+	if err != nil {
+		t.Fatalf("spawning services of %q failed: %v", consumerID, err)
+	}
+	return s
+}
+
 // -----
 // dummyService is a simple implementation
 // of Service for testing purposes.
@@ -159,8 +274,42 @@ func (s *dummyService) ID() string {
 }
 
 func (s *dummyService) Do() error {
+	defer s.wg.Done()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.incr++
 	s.callback(s.incr)
-	s.wg.Done()
 	return nil
 }
+
+// -----
+// ctxService is an implementation of ContextService
+// for testing purposes.
+// -----
+
+type ctxService struct {
+	id string
+	do func(ctx context.Context) error
+	wg *sync.WaitGroup
+}
+
+func newCtxService(id string, do func(ctx context.Context) error, wg *sync.WaitGroup) services.Service {
+	return &ctxService{
+		id: id,
+		do: do,
+		wg: wg,
+	}
+}
+
+func (s *ctxService) ID() string {
+	return s.id
+}
+
+func (s *ctxService) Do() error {
+	return s.DoContext(context.Background())
+}
+
+func (s *ctxService) DoContext(ctx context.Context) error {
+	defer s.wg.Done()
+	return s.do(ctx)
+}
@@ -0,0 +1,99 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// ErrProviderStopped is returned by a Provider which is stopping
+// or already stopped.
+var ErrProviderStopped = errors.New("provider is stopped")
+
+// runningSpawn describes a Spawning of a Provider not yet done.
+type runningSpawn struct {
+	consumerID string
+	cancel     context.CancelFunc
+}
+
+// Aborted describes a service execution cancelled when stopping
+// a Provider.
+type Aborted struct {
+	ConsumerID string
+	ServiceID  string
+}
+
+// ShutdownSummary describes the results of stopping a Provider.
+type ShutdownSummary struct {
+	// Drained is the number of spawnings which have been
+	// running when stopping and are done in time.
+	Drained int
+
+	// Aborted lists the service executions which had to be
+	// cancelled.
+	Aborted []Aborted
+}
+
+// Stop stops the Provider gracefully. New calls are rejected with
+// ErrProviderStopped while running spawnings are awaited until the
+// context is done. Then the remaining ones are cancelled, listed in
+// the returned ShutdownSummary, and the error of the context is
+// returned.
+func (p *Provider) Stop(ctx context.Context) (ShutdownSummary, error) {
+	var summary ShutdownSummary
+	running := make(map[*Spawning]runningSpawn)
+	if err := p.doSync(func() {
+		p.stopping = true
+		for s, rs := range p.running {
+			running[s] = rs
+		}
+	}); err != nil {
+		return summary, err
+	}
+	defer p.stopOnce.Do(func() {
+		close(p.stopC)
+	})
+	for s := range running {
+		select {
+		case <-s.Done():
+			summary.Drained++
+			delete(running, s)
+		case <-ctx.Done():
+			summary.Aborted = abort(running)
+			return summary, ctx.Err()
+		}
+	}
+	return summary, nil
+}
+
+// abort cancels the running spawnings and returns the services
+// without result. Those are collected before cancelling, otherwise
+// cancelled services could add their results in between.
+func abort(running map[*Spawning]runningSpawn) []Aborted {
+	var aborted []Aborted
+	for s, rs := range running {
+		for _, svcID := range s.Pending() {
+			aborted = append(aborted, Aborted{
+				ConsumerID: rs.consumerID,
+				ServiceID:  svcID,
+			})
+		}
+	}
+	for _, rs := range running {
+		rs.cancel()
+	}
+	sort.Slice(aborted, func(i, j int) bool {
+		if aborted[i].ConsumerID == aborted[j].ConsumerID {
+			return aborted[i].ServiceID < aborted[j].ServiceID
+		}
+		return aborted[i].ConsumerID < aborted[j].ConsumerID
+	})
+	return aborted
+}
@@ -0,0 +1,127 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestProviderStopDrain validates the stopping of a Provider
+// waiting for the running services.
+func TestProviderStopDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx)
+	var wg sync.WaitGroup
+	p.Book("foo", newCtxService("a", func(ctx context.Context) error {
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}, &wg))
+
+	wg.Add(1)
+	s := mustSpawn(t, p, "foo")
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second)
+	defer stopCancel()
+	summary, err := p.Stop(stopCtx)
+	if err != nil {
+		t.Fatalf("stopping failed: %v", err)
+	}
+	if summary.Drained != 1 || len(summary.Aborted) != 0 {
+		t.Fatalf("invalid shutdown summary: %v", summary)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("drained services failed: %v", err)
+	}
+
+	if _, err := p.Book("foo", newCtxService("b", nil, &wg)); err != services.ErrProviderStopped {
+		t.Fatalf("booking after stop returned wrong error: %v", err)
+	}
+	if _, err := p.Unbook("foo", "a"); err != services.ErrProviderStopped {
+		t.Fatalf("unbooking after stop returned wrong error: %v", err)
+	}
+	if _, err := p.Spawn("foo"); !errors.Is(err, services.ErrProviderStopped) {
+		t.Fatalf("spawning after stop returned wrong error: %v", err)
+	}
+	if consumerIDs := p.Consumers(); len(consumerIDs) != 0 {
+		t.Fatalf("stopped provider returned consumers: %v", consumerIDs)
+	}
+	if _, err := p.Stop(stopCtx); err != services.ErrProviderStopped {
+		t.Fatalf("stopping twice returned wrong error: %v", err)
+	}
+}
+
+// TestProviderStopAbort validates the cancellation of services
+// not done in time when stopping a Provider.
+func TestProviderStopAbort(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx)
+	var wg sync.WaitGroup
+	cancelledC := make(chan struct{})
+	p.Book("foo",
+		newCtxService("a", func(ctx context.Context) error {
+			return nil
+		}, &wg),
+		newCtxService("b", func(ctx context.Context) error {
+			<-ctx.Done()
+			close(cancelledC)
+			return ctx.Err()
+		}, &wg),
+	)
+	p.Book("bar", newCtxService("c", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, &wg))
+
+	wg.Add(3)
+	mustSpawn(t, p, "foo")
+	mustSpawn(t, p, "bar")
+	time.Sleep(10 * time.Millisecond)
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer stopCancel()
+	summary, err := p.Stop(stopCtx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("stopping returned wrong error: %v", err)
+	}
+	expected := []services.Aborted{
+		{ConsumerID: "bar", ServiceID: "c"},
+		{ConsumerID: "foo", ServiceID: "b"},
+	}
+	if !reflect.DeepEqual(summary.Aborted, expected) {
+		t.Fatalf("invalid aborted services: %v", summary.Aborted)
+	}
+	select {
+	case <-cancelledC:
+	case <-time.After(time.Second):
+		t.Fatalf("aborted service has not been cancelled")
+	}
+}
+
+// TestProviderContextDone validates the rejection of calls after
+// the context of the Provider is done.
+func TestProviderContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := services.StartProvider(ctx)
+	var wg sync.WaitGroup
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := p.Book("foo", newCtxService("a", nil, &wg)); err != services.ErrProviderStopped {
+		t.Fatalf("booking returned wrong error: %v", err)
+	}
+	if _, err := p.Spawn("foo"); !errors.Is(err, services.ErrProviderStopped) {
+		t.Fatalf("spawning returned wrong error: %v", err)
+	}
+}
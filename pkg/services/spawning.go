@@ -0,0 +1,304 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpawnStatus describes the state of a Spawning.
+type SpawnStatus int
+
+const (
+	// SpawnPending marks a Spawning whose services wait for
+	// their execution.
+	SpawnPending SpawnStatus = iota + 1
+
+	// SpawnRunning marks a Spawning with at least one started
+	// service execution.
+	SpawnRunning
+
+	// SpawnSucceeded marks a Spawning whose services all
+	// succeeded.
+	SpawnSucceeded
+
+	// SpawnFailed marks a Spawning with failed services.
+	SpawnFailed
+
+	// SpawnCancelled marks a cancelled Spawning.
+	SpawnCancelled
+)
+
+// String implements fmt.Stringer.
+func (ss SpawnStatus) String() string {
+	switch ss {
+	case SpawnPending:
+		return "pending"
+	case SpawnRunning:
+		return "running"
+	case SpawnSucceeded:
+		return "succeeded"
+	case SpawnFailed:
+		return "failed"
+	case SpawnCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// SpawnInfo describes a Spawning. Ended is zero while it is
+// not done.
+type SpawnInfo struct {
+	ID         string
+	ConsumerID string
+	Status     SpawnStatus
+	Started    time.Time
+	Ended      time.Time
+	Pending    []string
+}
+
+// Result contains the outcome of one service execution. The
+// attempts include retries. In case the execution exceeded its
+// timeout Err is a *TimeoutError. Services skipped due to failed
+// dependencies have no attempts and a *DependencyError.
+type Result struct {
+	ServiceID string
+	Started   time.Time
+	Duration  time.Duration
+	Attempts  int
+	Err       error
+}
+
+// rejected checks if the error of a Result tells that the service
+// has not been executed at all.
+func rejected(err error) bool {
+	var lerr *LimitError
+	var derr *DependencyError
+	var cerr *CycleError
+	return errors.As(err, &lerr) || errors.As(err, &derr) || errors.As(err, &cerr) ||
+		errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrOverlapSkipped) ||
+		errors.Is(err, ErrPoolExhausted)
+}
+
+// Spawning is the handle of concurrently executed services. It
+// allows to wait for their end and to retrieve their results.
+type Spawning struct {
+	mu         sync.Mutex
+	id         string
+	consumerID string
+	doneC      chan struct{}
+	svcIDs     []string
+	results    map[string]Result
+	observe    func(Result)
+	started    time.Time
+	ended      time.Time
+	executing  bool
+	cancelled  bool
+}
+
+// newSpawning creates a new handle for spawned services. The
+// optional observe function is called for each added Result.
+func newSpawning(observe func(Result)) *Spawning {
+	return &Spawning{
+		doneC:   make(chan struct{}),
+		results: make(map[string]Result),
+		observe: observe,
+	}
+}
+
+// ID returns the ID of a Spawning of a Provider. It is empty for
+// Services spawned directly.
+func (s *Spawning) ID() string {
+	return s.id
+}
+
+// Status returns the current SpawnStatus.
+func (s *Spawning) Status() SpawnStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status()
+}
+
+// Info returns the description of the Spawning.
+func (s *Spawning) Info() SpawnInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SpawnInfo{
+		ID:         s.id,
+		ConsumerID: s.consumerID,
+		Status:     s.status(),
+		Started:    s.started,
+		Ended:      s.ended,
+		Pending:    s.pending(),
+	}
+}
+
+// Done returns a channel which is closed when all spawned services
+// have been executed.
+func (s *Spawning) Done() <-chan struct{} {
+	return s.doneC
+}
+
+// Wait waits until all spawned services have been executed and
+// returns their results ordered by service ID.
+func (s *Spawning) Wait() []Result {
+	<-s.doneC
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]Result, 0, len(s.results))
+	for _, result := range s.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ServiceID < results[j].ServiceID
+	})
+	return results
+}
+
+// Result returns the result of the service with the given ID. The
+// flag is false if it has not been spawned or is not yet done.
+func (s *Spawning) Result(svcID string) (Result, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[svcID]
+	return result, ok
+}
+
+// Pending returns the IDs of the spawned services which are not
+// yet done.
+func (s *Spawning) Pending() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending()
+}
+
+// pending returns the IDs of the services not yet done.
+func (s *Spawning) pending() []string {
+	var pending []string
+	for _, svcID := range s.svcIDs {
+		if _, ok := s.results[svcID]; !ok {
+			pending = append(pending, svcID)
+		}
+	}
+	return pending
+}
+
+// Err waits until all spawned services have been executed. In case
+// of failed services a *SpawnError is returned, otherwise nil.
+func (s *Spawning) Err() error {
+	var failed []Result
+	for _, result := range s.Wait() {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &SpawnError{
+		Failed: failed,
+	}
+}
+
+// status determines the current SpawnStatus.
+func (s *Spawning) status() SpawnStatus {
+	if s.ended.IsZero() {
+		if s.executing {
+			return SpawnRunning
+		}
+		return SpawnPending
+	}
+	if s.cancelled {
+		return SpawnCancelled
+	}
+	status := SpawnSucceeded
+	for _, result := range s.results {
+		switch {
+		case errors.Is(result.Err, context.Canceled):
+			return SpawnCancelled
+		case result.Err != nil:
+			status = SpawnFailed
+		}
+	}
+	return status
+}
+
+// start sets the IDs of the spawned services.
+func (s *Spawning) start(svcIDs []string) {
+	sort.Strings(svcIDs)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.svcIDs = svcIDs
+	s.started = time.Now()
+}
+
+// execute marks the start of a service execution.
+func (s *Spawning) execute() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executing = true
+}
+
+// cancel marks the Spawning as cancelled if services are still
+// pending. Otherwise all results are already in.
+func (s *Spawning) cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending()) > 0 {
+		s.cancelled = true
+	}
+}
+
+// add stores the result of one service execution.
+func (s *Spawning) add(result Result) {
+	s.mu.Lock()
+	s.results[result.ServiceID] = result
+	s.mu.Unlock()
+	if s.observe != nil {
+		s.observe(result)
+	}
+}
+
+// finish signals the end of all executions.
+func (s *Spawning) finish() {
+	s.mu.Lock()
+	s.ended = time.Now()
+	s.mu.Unlock()
+	close(s.doneC)
+}
+
+// SpawnError aggregates the results of all failed services
+// of a Spawning.
+type SpawnError struct {
+	Failed []Result
+}
+
+// Error implements the error interface.
+func (e *SpawnError) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, result := range e.Failed {
+		msgs[i] = fmt.Sprintf("service %q: %v", result.ServiceID, result.Err)
+	}
+	return fmt.Sprintf("execution of %d service(s) failed: %s", len(e.Failed), strings.Join(msgs, "; "))
+}
+
+// Errors returns the individual errors of the failed services.
+func (e *SpawnError) Errors() []error {
+	errs := make([]error, len(e.Failed))
+	for i, result := range e.Failed {
+		errs[i] = result.Err
+	}
+	return errs
+}
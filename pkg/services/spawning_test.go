@@ -0,0 +1,101 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestSpawningResults validates the results of spawned services
+// including the aggregated error.
+func TestSpawningResults(t *testing.T) {
+	var wg sync.WaitGroup
+	errB := errors.New("b failed")
+	errC := errors.New("c failed")
+	svcs := services.Services{
+		"a": newCtxService("a", func(ctx context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}, &wg),
+		"b": newCtxService("b", func(ctx context.Context) error { return errB }, &wg),
+		"c": newCtxService("c", func(ctx context.Context) error { return errC }, &wg),
+	}
+
+	wg.Add(3)
+	s := svcs.Spawn()
+	results := s.Wait()
+
+	if len(results) != 3 {
+		t.Fatalf("invalid number of results, expect 3: %d", len(results))
+	}
+	for i, id := range []string{"a", "b", "c"} {
+		if results[i].ServiceID != id {
+			t.Fatalf("result %d has wrong service ID: %q", i, results[i].ServiceID)
+		}
+	}
+	if results[0].Err != nil {
+		t.Fatalf("a returned error: %v", results[0].Err)
+	}
+	if results[0].Duration < 20*time.Millisecond {
+		t.Fatalf("a has wrong duration: %v", results[0].Duration)
+	}
+	if result, ok := s.Result("b"); !ok || result.Err != errB {
+		t.Fatalf("b has wrong result: %v", result)
+	}
+
+	err := s.Err()
+	var serr *services.SpawnError
+	if !errors.As(err, &serr) {
+		t.Fatalf("error has wrong type: %v", err)
+	}
+	errs := serr.Errors()
+	if len(errs) != 2 || errs[0] != errB || errs[1] != errC {
+		t.Fatalf("spawn error contains wrong errors: %v", errs)
+	}
+}
+
+// TestProviderSpawning validates the waiting for the results of
+// services spawned by a Provider.
+func TestProviderSpawning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx)
+	var wg sync.WaitGroup
+	svca := newCtxService("a", func(ctx context.Context) error { return nil }, &wg)
+	svcb := newCtxService("b", func(ctx context.Context) error { return errors.New("ouch") }, &wg)
+
+	p.Book("foo", svca, svcb)
+
+	wg.Add(2)
+	s := mustSpawn(t, p, "foo")
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("spawning not done")
+	}
+	if err := s.Err(); err == nil {
+		t.Fatalf("spawning returned no error")
+	}
+	if result, ok := s.Result("a"); !ok || result.Err != nil {
+		t.Fatalf("a has wrong result: %v", result)
+	}
+
+	s = mustSpawn(t, p, "bar")
+	if results := s.Wait(); len(results) != 0 {
+		t.Fatalf("unknown consumer has results: %v", results)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("unknown consumer has error: %v", err)
+	}
+}
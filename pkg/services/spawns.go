@@ -0,0 +1,93 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnknownSpawn is returned for IDs of unknown or no longer
+// retained spawnings.
+var ErrUnknownSpawn = errors.New("unknown spawning")
+
+// WithSpawnRetention sets how many finished spawnings the Provider
+// keeps for status queries. The default is 100, negative values
+// are handled like zero.
+func WithSpawnRetention(n int) Option {
+	return func(p *Provider) {
+		if n < 0 {
+			n = 0
+		}
+		p.retention = n
+	}
+}
+
+// SpawnInfo returns the description of the Spawning with the given
+// ID. Finished spawnings are only known within the retention.
+func (p *Provider) SpawnInfo(spawnID string) (SpawnInfo, bool) {
+	var s *Spawning
+	if err := p.doSync(func() {
+		s = p.spawns[spawnID]
+	}); err != nil || s == nil {
+		return SpawnInfo{}, false
+	}
+	return s.Info(), true
+}
+
+// ActiveSpawns returns the descriptions of the spawnings not yet
+// done ordered by their start.
+func (p *Provider) ActiveSpawns() []SpawnInfo {
+	var running []*Spawning
+	if err := p.doSync(func() {
+		for s := range p.running {
+			running = append(running, s)
+		}
+	}); err != nil {
+		return nil
+	}
+	infos := make([]SpawnInfo, 0, len(running))
+	for _, s := range running {
+		infos = append(infos, s.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Started.Before(infos[j].Started)
+	})
+	return infos
+}
+
+// CancelSpawn cancels the context of the Spawning with the given ID.
+// Cancelling a done Spawning has no effect.
+func (p *Provider) CancelSpawn(spawnID string) error {
+	var err error
+	if serr := p.doSync(func() {
+		s, ok := p.spawns[spawnID]
+		if !ok {
+			err = fmt.Errorf("cannot cancel spawning %q: %w", spawnID, ErrUnknownSpawn)
+			return
+		}
+		if rs, ok := p.running[s]; ok {
+			s.cancel()
+			rs.cancel()
+		}
+	}); serr != nil {
+		return serr
+	}
+	return err
+}
+
+// retain keeps a finished Spawning and drops the oldest ones
+// exceeding the retention. It has to be called in the backend.
+func (p *Provider) retain(s *Spawning) {
+	p.finished = append(p.finished, s.id)
+	for len(p.finished) > p.retention {
+		delete(p.spawns, p.finished[0])
+		p.finished = p.finished[1:]
+	}
+}
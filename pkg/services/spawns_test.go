@@ -0,0 +1,129 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestProviderSpawnStatus validates the status of tracked spawnings
+// and their cancellation.
+func TestProviderSpawnStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithPool(services.PoolConfig{MaxConcurrency: 1}))
+	blocker := newBlockingService("block")
+	if _, err := p.Book("b", blocker); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+	if _, err := p.Book("c", echoService{id: "a"}); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+	status := func(spawnID string, expected services.SpawnStatus) services.SpawnInfo {
+		t.Helper()
+		info, ok := p.SpawnInfo(spawnID)
+		if !ok {
+			t.Fatalf("spawning %q not found", spawnID)
+		}
+		if info.Status != expected {
+			t.Fatalf("spawning %q is %v, expect %v", spawnID, info.Status, expected)
+		}
+		return info
+	}
+
+	sb := mustSpawn(t, p, "b")
+	// Spawn c only when the blocker holds the only slot.
+	select {
+	case <-blocker.startedC:
+	case <-time.After(time.Second):
+		t.Fatalf("blocker has not been started")
+	}
+	sc := mustSpawn(t, p, "c")
+	if sb.ID() == "" || sb.ID() == sc.ID() {
+		t.Fatalf("invalid spawn IDs %q and %q", sb.ID(), sc.ID())
+	}
+	status(sb.ID(), services.SpawnRunning)
+	info := status(sc.ID(), services.SpawnPending)
+	if info.ConsumerID != "c" || len(info.Pending) != 1 || info.Pending[0] != "a" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	active := p.ActiveSpawns()
+	if len(active) != 2 || active[0].ID != sb.ID() || active[1].ID != sc.ID() {
+		t.Fatalf("unexpected active spawnings: %+v", active)
+	}
+
+	if err := p.CancelSpawn(sb.ID()); err != nil {
+		t.Fatalf("cancelling failed: %v", err)
+	}
+	sb.Wait()
+	sc.Wait()
+	info = status(sb.ID(), services.SpawnCancelled)
+	if info.Ended.Before(info.Started) || len(info.Pending) != 0 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	status(sc.ID(), services.SpawnSucceeded)
+	if active := p.ActiveSpawns(); len(active) != 0 {
+		t.Fatalf("unexpected active spawnings: %+v", active)
+	}
+	if err := p.CancelSpawn(sc.ID()); err != nil {
+		t.Fatalf("cancelling done spawning failed: %v", err)
+	}
+	status(sc.ID(), services.SpawnSucceeded)
+	if err := p.CancelSpawn("unknown"); !errors.Is(err, services.ErrUnknownSpawn) {
+		t.Fatalf("expected unknown spawning error, got %v", err)
+	}
+}
+
+// TestProviderSpawnRetention validates that only the latest finished
+// spawnings are kept.
+func TestProviderSpawnRetention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithSpawnRetention(1))
+	if _, err := p.Book("c", echoService{id: "fail", Fail: true}); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+
+	first := mustSpawn(t, p, "c")
+	first.Wait()
+	second := mustSpawn(t, p, "c")
+	second.Wait()
+	if _, ok := p.SpawnInfo(first.ID()); ok {
+		t.Fatalf("first spawning is still retained")
+	}
+	info, ok := p.SpawnInfo(second.ID())
+	if !ok || info.Status != services.SpawnFailed {
+		t.Fatalf("unexpected info of second spawning: %+v", info)
+	}
+}
+
+// TestProviderNegativeSpawnRetention validates that a negative
+// retention keeps no finished spawnings.
+func TestProviderNegativeSpawnRetention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithSpawnRetention(-1))
+	if _, err := p.Book("c", echoService{id: "a"}); err != nil {
+		t.Fatalf("booking failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		s := mustSpawn(t, p, "c")
+		if err := s.Err(); err != nil {
+			t.Fatalf("spawning failed: %v", err)
+		}
+		if _, ok := p.SpawnInfo(s.ID()); ok {
+			t.Fatalf("finished spawning is retained")
+		}
+	}
+}
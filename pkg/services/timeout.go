@@ -0,0 +1,112 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// TimeoutError is returned as result of a service execution which
+// has been cancelled because it exceeded its timeout.
+type TimeoutError struct {
+	ServiceID string
+	Timeout   time.Duration
+}
+
+// Error implements the error interface.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("execution of service %q timed out after %v", e.ServiceID, e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Timeouter is implemented by services defining their own
+// execution timeout.
+type Timeouter interface {
+	// Timeout returns the maximum duration of an execution
+	// including retries.
+	Timeout() time.Duration
+}
+
+// timeoutService decorates a Service with a timeout.
+type timeoutService struct {
+	wrapper
+	timeout time.Duration
+}
+
+// WithTimeout decorates a Service with a timeout, e.g. to use
+// different timeouts for different bookings.
+func WithTimeout(svc Service, timeout time.Duration) Service {
+	return &timeoutService{
+		wrapper: wrapper{svc},
+		timeout: timeout,
+	}
+}
+
+// Timeout implements Timeouter.
+func (ts *timeoutService) Timeout() time.Duration {
+	return ts.timeout
+}
+
+// timeoutOf returns the timeout of a Service. It may be implemented
+// by the Service itself or one of its decorators. Zero means no
+// timeout.
+func timeoutOf(svc Service) time.Duration {
+	var timeout time.Duration
+	lookup(svc, func(s Service) bool {
+		if t, ok := s.(Timeouter); ok {
+			timeout = t.Timeout()
+			return true
+		}
+		return false
+	})
+	return timeout
+}
+
+// doTimed executes the Service including retries like doRetrying.
+// If it exceeds the timeout its context is cancelled and a
+// *TimeoutError is returned. Services not reacting on the
+// cancellation are abandoned. The returned channel is closed
+// when the execution has really ended.
+func doTimed(ctx context.Context, svcID string, svc Service, timeout time.Duration, attempts *int32) (<-chan struct{}, error) {
+	timedCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	errC := make(chan error, 1)
+	endedC := make(chan struct{})
+	go func() {
+		defer close(endedC)
+		errC <- doRetrying(timedCtx, svc, attempts)
+	}()
+	var err error
+	select {
+	case err = <-errC:
+		if err == nil {
+			return endedC, nil
+		}
+	case <-timedCtx.Done():
+		err = timedCtx.Err()
+	}
+	if ctx.Err() == nil && timedCtx.Err() == context.DeadlineExceeded {
+		return endedC, &TimeoutError{
+			ServiceID: svcID,
+			Timeout:   timeout,
+		}
+	}
+	return endedC, err
+}
+
+// countAttempt increments the number of attempts.
+func countAttempt(attempts *int32) {
+	atomic.AddInt32(attempts, 1)
+}
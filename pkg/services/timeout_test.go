@@ -0,0 +1,166 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestSpawnTimeout validates the timeout of booked services
+// reacting on the context cancellation or not.
+func TestSpawnTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	releaseC := make(chan struct{})
+	defer close(releaseC)
+	svcs := services.Services{
+		"a": services.WithTimeout(newCtxService("a", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, &wg), 20*time.Millisecond),
+		"b": services.WithTimeout(newCtxService("b", func(ctx context.Context) error {
+			// Ignores the context.
+			<-releaseC
+			return nil
+		}, &wg), 20*time.Millisecond),
+		"c": services.WithTimeout(newCtxService("c", func(ctx context.Context) error {
+			return errors.New("ouch")
+		}, &wg), time.Second),
+		"d": services.WithTimeout(newCtxService("d", func(ctx context.Context) error {
+			return nil
+		}, &wg), time.Second),
+	}
+
+	wg.Add(4)
+	started := time.Now()
+	results := svcs.Spawn().Wait()
+	if time.Since(started) > 500*time.Millisecond {
+		t.Fatalf("timeouts took too long: %v", time.Since(started))
+	}
+	for _, result := range results[:2] {
+		var terr *services.TimeoutError
+		if !errors.As(result.Err, &terr) {
+			t.Fatalf("%s did not time out: %v", result.ServiceID, result.Err)
+		}
+		if terr.ServiceID != result.ServiceID || terr.Timeout != 20*time.Millisecond {
+			t.Fatalf("%s has invalid timeout error: %v", result.ServiceID, terr)
+		}
+		if !errors.Is(result.Err, context.DeadlineExceeded) {
+			t.Fatalf("%s timeout error does not wrap deadline error", result.ServiceID)
+		}
+	}
+	if err := results[2].Err; err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("c has wrong error: %v", err)
+	}
+	if err := results[3].Err; err != nil {
+		t.Fatalf("d has error: %v", err)
+	}
+}
+
+// TestProviderTimeouts validates timeouts configured per service
+// and per booking.
+func TestProviderTimeouts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx, services.WithTimeouts(20*time.Millisecond, "a", "b"))
+	var wg sync.WaitGroup
+	sleeping := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return nil
+		}
+	}
+	p.Book("foo",
+		newCtxService("a", sleeping, &wg),
+		services.WithTimeout(newCtxService("b", sleeping, &wg), time.Second),
+		newCtxService("c", sleeping, &wg),
+	)
+
+	wg.Add(3)
+	s := mustSpawn(t, p, "foo")
+	results := s.Wait()
+	var terr *services.TimeoutError
+	if !errors.As(results[0].Err, &terr) {
+		t.Fatalf("a did not time out: %v", results[0].Err)
+	}
+	if results[1].Err != nil {
+		t.Fatalf("b failed: %v", results[1].Err)
+	}
+	if results[2].Err != nil {
+		t.Fatalf("c failed: %v", results[2].Err)
+	}
+
+	// Cancellation by the caller is no timeout.
+	spawnCtx, spawnCancel := context.WithCancel(context.Background())
+	wg.Add(3)
+	s = mustSpawnContext(t, p, spawnCtx, "foo")
+	spawnCancel()
+	for _, result := range s.Wait() {
+		if errors.As(result.Err, &terr) {
+			t.Fatalf("%s has timeout error after cancellation", result.ServiceID)
+		}
+		if result.Err != context.Canceled {
+			t.Fatalf("%s has wrong error: %v", result.ServiceID, result.Err)
+		}
+	}
+}
+
+// TestProviderAbandonedTimeouts validates that services abandoned
+// after their timeout keep their pool slot until they have ended.
+func TestProviderAbandonedTimeouts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := services.StartProvider(ctx,
+		services.WithPool(services.PoolConfig{MaxConcurrency: 1}),
+		services.WithTimeouts(10*time.Millisecond, "a", "b"),
+	)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var running, maxRunning int
+	ignoring := func(ctx context.Context) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		// Ignores the context.
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+	p.Book("foo",
+		newCtxService("a", ignoring, &wg),
+		newCtxService("b", ignoring, &wg),
+	)
+
+	wg.Add(2)
+	s := mustSpawn(t, p, "foo")
+	for _, result := range s.Wait() {
+		var terr *services.TimeoutError
+		if !errors.As(result.Err, &terr) {
+			t.Fatalf("%s did not time out: %v", result.ServiceID, result.Err)
+		}
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxRunning != 1 {
+		t.Fatalf("invalid maximum of running services, expect 1: %d", maxRunning)
+	}
+}
@@ -0,0 +1,266 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Trigger fires the spawning of consumer bookings, e.g. by time,
+// changed files, HTTP requests, or signals.
+type Trigger interface {
+	// Run calls fire for each event until the context is done
+	// or the Trigger fails. fire returns the error of a rejected
+	// spawning, so the Trigger can report it to its source.
+	Run(ctx context.Context, fire func() error) error
+}
+
+// SpawnHandler is called with the outcome of each spawning fired
+// by a Trigger.
+type SpawnHandler func(consumerID string, s *Spawning, err error)
+
+// RunTrigger runs the Trigger and spawns the bookings of the
+// consumers each time it fires. The optional handler receives the
+// outcome of each spawning. It returns when the context is done or
+// the Trigger fails.
+func RunTrigger(ctx context.Context, t Trigger, spawner Spawner, handler SpawnHandler, consumerIDs ...string) error {
+	err := t.Run(ctx, func() error {
+		var ferr error
+		for _, consumerID := range consumerIDs {
+			s, err := spawner.Spawn(consumerID)
+			if handler != nil {
+				handler(consumerID, s, err)
+			}
+			if err != nil && ferr == nil {
+				ferr = fmt.Errorf("cannot spawn bookings of consumer %q: %w", consumerID, err)
+			}
+		}
+		return ferr
+	})
+	if err != nil && !errors.Is(err, ctx.Err()) {
+		return fmt.Errorf("trigger failed: %w", err)
+	}
+	return nil
+}
+
+// IntervalTrigger fires in a fixed interval.
+type IntervalTrigger struct {
+	Interval time.Duration
+}
+
+// Run implements Trigger.
+func (it IntervalTrigger) Run(ctx context.Context, fire func() error) error {
+	if it.Interval <= 0 {
+		return fmt.Errorf("invalid interval %v", it.Interval)
+	}
+	ticker := time.NewTicker(it.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			// Rejections are reported to the SpawnHandler.
+			_ = fire()
+		}
+	}
+}
+
+// FileTrigger fires when the file at the path is created, changed,
+// or removed. Changes are detected by polling its modification time
+// and size.
+type FileTrigger struct {
+	Path string
+
+	// PollInterval defines how often the file is checked. The
+	// default is one second.
+	PollInterval time.Duration
+}
+
+// Run implements Trigger.
+func (ft FileTrigger) Run(ctx context.Context, fire func() error) error {
+	interval := ft.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	last, err := ft.stat()
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			current, err := ft.stat()
+			if err != nil {
+				return err
+			}
+			if current != last {
+				last = current
+				_ = fire()
+			}
+		}
+	}
+}
+
+// fileState describes the state of a watched file.
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// stat returns the state of the file.
+func (ft FileTrigger) stat() (fileState, error) {
+	fi, err := os.Stat(ft.Path)
+	switch {
+	case os.IsNotExist(err):
+		return fileState{}, nil
+	case err != nil:
+		return fileState{}, fmt.Errorf("cannot watch file %q: %v", ft.Path, err)
+	}
+	return fileState{
+		exists:  true,
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+	}, nil
+}
+
+// HTTPTrigger is an http.Handler firing on POST requests while it is
+// running. If a token is set the requests have to authorize with it
+// as bearer token.
+type HTTPTrigger struct {
+	token string
+	fireC chan chan error
+	mu    sync.Mutex
+	doneC <-chan struct{}
+}
+
+// NewHTTPTrigger creates an HTTPTrigger. An empty token disables the
+// authorization.
+func NewHTTPTrigger(token string) *HTTPTrigger {
+	return &HTTPTrigger{
+		token: token,
+		fireC: make(chan chan error),
+	}
+}
+
+// Run implements Trigger.
+func (ht *HTTPTrigger) Run(ctx context.Context, fire func() error) error {
+	ht.mu.Lock()
+	ht.doneC = ctx.Done()
+	ht.mu.Unlock()
+	defer func() {
+		ht.mu.Lock()
+		ht.doneC = nil
+		ht.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case firedC := <-ht.fireC:
+			firedC <- fire()
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler. Requests are answered with
+// 202 Accepted when the Trigger fired, with 429 Too Many Requests
+// when a spawning exceeded the limits of its consumer, and with 503
+// Service Unavailable when it is not running or a spawning has been
+// rejected otherwise.
+func (ht *HTTPTrigger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ht.token != "" && !ht.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ht.mu.Lock()
+	doneC := ht.doneC
+	ht.mu.Unlock()
+	if doneC == nil {
+		http.Error(w, "trigger not running", http.StatusServiceUnavailable)
+		return
+	}
+	firedC := make(chan error, 1)
+	select {
+	case ht.fireC <- firedC:
+		writeFired(w, <-firedC)
+	case <-doneC:
+		http.Error(w, "trigger not running", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+// authorized checks the bearer token of the request in constant
+// time to not leak information about the token.
+func (ht *HTTPTrigger) authorized(r *http.Request) bool {
+	expected := []byte("Bearer " + ht.token)
+	actual := []byte(r.Header.Get("Authorization"))
+	return subtle.ConstantTimeCompare(actual, expected) == 1
+}
+
+// writeFired answers a request depending on the error of the
+// fired spawnings.
+func writeFired(w http.ResponseWriter, err error) {
+	var lerr *LimitError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errors.As(err, &lerr):
+		if lerr.RetryAfter > 0 {
+			secs := int(math.Ceil(lerr.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+		}
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+// SignalTrigger fires when the process receives one of the signals,
+// e.g. syscall.SIGHUP.
+type SignalTrigger struct {
+	Signals []os.Signal
+}
+
+// Run implements Trigger.
+func (st SignalTrigger) Run(ctx context.Context, fire func() error) error {
+	if len(st.Signals) == 0 {
+		return fmt.Errorf("no signals to watch")
+	}
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, st.Signals...)
+	defer signal.Stop(sigC)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sigC:
+			_ = fire()
+		}
+	}
+}
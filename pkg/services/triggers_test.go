@@ -0,0 +1,225 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package services_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestIntervalTrigger validates the spawning by an IntervalTrigger.
+func TestIntervalTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	spawner, spawnedC := newTriggeredSpawner()
+	errC := runTrigger(ctx, services.IntervalTrigger{Interval: 10 * time.Millisecond}, spawner, "a", "b")
+
+	awaitSpawn(t, spawnedC, "a")
+	awaitSpawn(t, spawnedC, "b")
+	awaitSpawn(t, spawnedC, "a")
+	cancel()
+	if err := <-errC; err != nil {
+		t.Fatalf("trigger returned error: %v", err)
+	}
+
+	err := services.RunTrigger(context.Background(), services.IntervalTrigger{}, spawner, nil, "a")
+	if err == nil {
+		t.Fatalf("trigger with invalid interval did not fail")
+	}
+}
+
+// TestFileTrigger validates the spawning by a FileTrigger.
+func TestFileTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir, err := ioutil.TempDir("", "trigger")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "watched")
+	spawner, spawnedC := newTriggeredSpawner()
+	runTrigger(ctx, services.FileTrigger{Path: path, PollInterval: 5 * time.Millisecond}, spawner, "a")
+	time.Sleep(20 * time.Millisecond)
+	assertNoSpawn(t, spawnedC)
+
+	// Creation, change, and removal.
+	if err := ioutil.WriteFile(path, []byte("one"), 0644); err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	awaitSpawn(t, spawnedC, "a")
+	if err := ioutil.WriteFile(path, []byte("one two"), 0644); err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	awaitSpawn(t, spawnedC, "a")
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("cannot remove file: %v", err)
+	}
+	awaitSpawn(t, spawnedC, "a")
+	time.Sleep(20 * time.Millisecond)
+	assertNoSpawn(t, spawnedC)
+}
+
+// TestHTTPTrigger validates the spawning by an HTTPTrigger.
+func TestHTTPTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trigger := services.NewHTTPTrigger("secret")
+	srv := httptest.NewServer(trigger)
+	defer srv.Close()
+	post := func(token string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, srv.URL, nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post("secret"); code != http.StatusServiceUnavailable {
+		t.Fatalf("not running trigger returned %d", code)
+	}
+	spawner, spawnedC := newTriggeredSpawner()
+	runTrigger(ctx, trigger, spawner, "a")
+	time.Sleep(10 * time.Millisecond)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET returned %d", resp.StatusCode)
+	}
+	if code := post("wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong token returned %d", code)
+	}
+	assertNoSpawn(t, spawnedC)
+	if code := post("secret"); code != http.StatusAccepted {
+		t.Fatalf("valid request returned %d", code)
+	}
+	awaitSpawn(t, spawnedC, "a")
+}
+
+// TestHTTPTriggerRejections validates the answers of an HTTPTrigger
+// when spawnings are rejected.
+func TestHTTPTriggerRejections(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trigger := services.NewHTTPTrigger("")
+	srv := httptest.NewServer(trigger)
+	defer srv.Close()
+	errs := map[string]error{
+		"limited": &services.LimitError{
+			ConsumerID: "limited",
+			RetryAfter: 1500 * time.Millisecond,
+			Err:        services.ErrRateLimited,
+		},
+		"stopped": services.ErrProviderStopped,
+	}
+	spawner := services.SpawnerFunc(func(consumerID string) (*services.Spawning, error) {
+		return nil, errs[consumerID]
+	})
+	handledC := make(chan error, 16)
+	handler := func(consumerID string, s *services.Spawning, err error) {
+		handledC <- err
+	}
+
+	tests := []struct {
+		consumerID string
+		code       int
+		retryAfter string
+	}{
+		{"limited", http.StatusTooManyRequests, "2"},
+		{"stopped", http.StatusServiceUnavailable, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.consumerID, func(t *testing.T) {
+			triggerCtx, triggerCancel := context.WithCancel(ctx)
+			errC := make(chan error, 1)
+			go func() {
+				errC <- services.RunTrigger(triggerCtx, trigger, spawner, handler, test.consumerID)
+			}()
+			defer func() {
+				triggerCancel()
+				<-errC
+			}()
+			time.Sleep(10 * time.Millisecond)
+
+			resp, err := http.Post(srv.URL, "text/plain", nil)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.code {
+				t.Fatalf("request returned %d, expect %d", resp.StatusCode, test.code)
+			}
+			if ra := resp.Header.Get("Retry-After"); ra != test.retryAfter {
+				t.Fatalf("request returned Retry-After %q, expect %q", ra, test.retryAfter)
+			}
+			if err := <-handledC; !errors.Is(err, errs[test.consumerID]) {
+				t.Fatalf("handler received %v", err)
+			}
+		})
+	}
+}
+
+// newTriggeredSpawner returns a Spawner sending the spawned consumer
+// IDs to the returned channel.
+func newTriggeredSpawner() (services.Spawner, <-chan string) {
+	spawnedC := make(chan string, 16)
+	return services.SpawnerFunc(func(consumerID string) (*services.Spawning, error) {
+		spawnedC <- consumerID
+		return services.Services{}.Spawn(), nil
+	}), spawnedC
+}
+
+// runTrigger runs the Trigger in the background.
+func runTrigger(ctx context.Context, trigger services.Trigger, spawner services.Spawner, consumerIDs ...string) <-chan error {
+	errC := make(chan error, 1)
+	go func() {
+		errC <- services.RunTrigger(ctx, trigger, spawner, nil, consumerIDs...)
+	}()
+	return errC
+}
+
+func awaitSpawn(t *testing.T, spawnedC <-chan string, expected string) {
+	t.Helper()
+	select {
+	case consumerID := <-spawnedC:
+		if consumerID != expected {
+			t.Fatalf("spawned %q, expect %q", consumerID, expected)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for spawning of %q", expected)
+	}
+}
+
+func assertNoSpawn(t *testing.T, spawnedC <-chan string) {
+	t.Helper()
+	select {
+	case consumerID := <-spawnedC:
+		t.Fatalf("unexpected spawning of %q", consumerID)
+	default:
+	}
+}
@@ -0,0 +1,35 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+//go:build !windows
+// +build !windows
+
+package services_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// TestSignalTrigger validates the spawning by a SignalTrigger.
+func TestSignalTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	spawner, spawnedC := newTriggeredSpawner()
+	runTrigger(ctx, services.SignalTrigger{Signals: []os.Signal{syscall.SIGUSR1}}, spawner, "a")
+	time.Sleep(10 * time.Millisecond)
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("cannot send signal: %v", err)
+	}
+	awaitSpawn(t, spawnedC, "a")
+}
@@ -0,0 +1,11 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+// Package webhook provides a service calling a URL with a payload
+// rendered from a template. Requests can be signed by an HMAC
+// so that receivers can verify them.
+package webhook
@@ -0,0 +1,236 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/themue/samples/pkg/services"
+)
+
+// ServiceType is the name of the webhook service type inside
+// a services.Catalog.
+const ServiceType = "webhook"
+
+// DefaultSignatureHeader is the header containing the signature
+// if no other one is configured.
+const DefaultSignatureHeader = "X-Signature-256"
+
+// defaultClient is used if no client is passed. Its timeout ensures
+// that hanging webhooks don't block the executions forever.
+var defaultClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// maxErrorBody limits the bytes of a response body kept in
+// a StatusError.
+const maxErrorBody = 4 * 1024
+
+// Config defines the request of a webhook. It is also the
+// configuration of the service inside a services.Spec.
+type Config struct {
+	// URL is the called URL.
+	URL string `json:"url"`
+
+	// Method is the HTTP method, by default POST.
+	Method string `json:"method,omitempty"`
+
+	// Headers are set for each request.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Body is a text/template rendered with the Data of the
+	// call as request body.
+	Body string `json:"body,omitempty"`
+
+	// Values are passed to the body template.
+	Values map[string]string `json:"values,omitempty"`
+
+	// Secret enables the signing of the body. The signature
+	// is the hex encoded HMAC-SHA256 prefixed with "sha256=".
+	Secret string `json:"secret,omitempty"`
+
+	// SignatureHeader is the header of the signature, by
+	// default DefaultSignatureHeader.
+	SignatureHeader string `json:"signature_header,omitempty"`
+
+	// ExpectedStatus contains the accepted status codes, by
+	// default all 2xx codes.
+	ExpectedStatus []int `json:"expected_status,omitempty"`
+}
+
+// Data is passed to the body template.
+type Data struct {
+	ServiceID string
+	Time      time.Time
+	Values    map[string]string
+}
+
+// StatusError is returned if the response has an unexpected
+// status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	body := strings.TrimSpace(e.Body)
+	if body == "" {
+		return fmt.Sprintf("webhook %q responded with status %d", e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("webhook %q responded with status %d: %s", e.URL, e.StatusCode, body)
+}
+
+// Retryable implements services.RetryableError. Server errors and
+// too many requests may be retried.
+func (e *StatusError) Retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Service implements services.Service calling a webhook.
+type Service struct {
+	id     string
+	cfg    Config
+	body   *template.Template
+	client *http.Client
+}
+
+// NewService creates a webhook service instance. If the client is
+// nil a default one with a timeout of 30 seconds is used.
+func NewService(id string, cfg Config, client *http.Client) (*Service, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("no webhook URL configured")
+	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodPost
+	}
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = DefaultSignatureHeader
+	}
+	body, err := template.New(id).Parse(cfg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %v", err)
+	}
+	if client == nil {
+		client = defaultClient
+	}
+	return &Service{
+		id:     id,
+		cfg:    cfg,
+		body:   body,
+		client: client,
+	}, nil
+}
+
+// ID implements services.Service.
+func (s *Service) ID() string {
+	return s.id
+}
+
+// Type implements services.Typed.
+func (s *Service) Type() string {
+	return ServiceType
+}
+
+// Do implements services.Service.
+func (s *Service) Do() error {
+	return s.DoContext(context.Background())
+}
+
+// DoContext implements services.ContextService. Failed requests and
+// responses with server errors are marked as retryable.
+func (s *Service) DoContext(ctx context.Context) error {
+	var body bytes.Buffer
+	err := s.body.Execute(&body, Data{
+		ServiceID: s.id,
+		Time:      time.Now(),
+		Values:    s.cfg.Values,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot render webhook body: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, s.cfg.Method, s.cfg.URL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("cannot create webhook request: %v", err)
+	}
+	for key, value := range s.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	if s.cfg.Secret != "" {
+		req.Header.Set(s.cfg.SignatureHeader, Sign([]byte(s.cfg.Secret), body.Bytes()))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("webhook %q aborted: %w", s.cfg.URL, ctxErr)
+		}
+		return services.Retryable(fmt.Errorf("cannot call webhook %q: %v", s.cfg.URL, err))
+	}
+	defer resp.Body.Close()
+	if s.expected(resp.StatusCode) {
+		// Drain to allow reusing the connection.
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &StatusError{
+		URL:        s.cfg.URL,
+		StatusCode: resp.StatusCode,
+		Body:       string(respBody),
+	}
+}
+
+// expected checks if the status code is expected.
+func (s *Service) expected(code int) bool {
+	if len(s.cfg.ExpectedStatus) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, expected := range s.cfg.ExpectedStatus {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature of a body as sent in the signature
+// header, so receivers can verify requests.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RegisterService registers the webhook service type in the catalog.
+// The created services share the client, nil uses the default
+// one of NewService.
+func RegisterService(catalog *services.Catalog, client *http.Client) error {
+	return catalog.Register(services.ServiceType{
+		Name:        ServiceType,
+		Description: "Calls a URL with a payload rendered from a template.",
+		NewConfig: func() interface{} {
+			return &Config{}
+		},
+		Create: func(id string, config interface{}) (services.Service, error) {
+			return NewService(id, *config.(*Config), client)
+		},
+	})
+}
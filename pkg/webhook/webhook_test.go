@@ -0,0 +1,200 @@
+// The Samples Project
+//
+// Copyright 2020-2021 Frank Mueller / Oldenburg / Germany / World
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package webhook_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/themue/samples/pkg/services"
+	"github.com/themue/samples/pkg/webhook"
+)
+
+// request is a request received by the test server.
+type request struct {
+	method string
+	header http.Header
+	body   string
+}
+
+// newTestServer starts a server answering with the status code and
+// passing the received requests to the channel.
+func newTestServer(t *testing.T, status int) (*httptest.Server, <-chan request) {
+	requestC := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read body: %v", err)
+		}
+		requestC <- request{
+			method: r.Method,
+			header: r.Header,
+			body:   string(body),
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("response body"))
+	}))
+	return srv, requestC
+}
+
+// TestServiceRequest verifies the request of the webhook including
+// the rendered body and its signature.
+func TestServiceRequest(t *testing.T) {
+	srv, requestC := newTestServer(t, http.StatusOK)
+	defer srv.Close()
+	svc, err := webhook.NewService("hook", webhook.Config{
+		URL:     srv.URL,
+		Method:  http.MethodPut,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    `{"service":"{{.ServiceID}}","team":"{{.Values.team}}"}`,
+		Values:  map[string]string{"team": "blue"},
+		Secret:  "secret",
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("creating service failed: %v", err)
+	}
+
+	if err := svc.Do(); err != nil {
+		t.Fatalf("calling webhook failed: %v", err)
+	}
+	req := <-requestC
+	expectedBody := `{"service":"hook","team":"blue"}`
+	if req.method != http.MethodPut || req.body != expectedBody {
+		t.Fatalf("unexpected request: %s %s", req.method, req.body)
+	}
+	if ct := req.header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	signature := req.header.Get(webhook.DefaultSignatureHeader)
+	if signature != webhook.Sign([]byte("secret"), []byte(expectedBody)) {
+		t.Fatalf("invalid signature %q", signature)
+	}
+}
+
+// TestServiceStatus verifies the handling of expected and unexpected
+// status codes.
+func TestServiceStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		expected  []int
+		fails     bool
+		retryable bool
+	}{
+		{"default-ok", http.StatusNoContent, nil, false, false},
+		{"default-client-error", http.StatusBadRequest, nil, true, false},
+		{"default-server-error", http.StatusBadGateway, nil, true, true},
+		{"too-many-requests", http.StatusTooManyRequests, nil, true, true},
+		{"configured", http.StatusNotFound, []int{http.StatusNotFound}, false, false},
+		{"not-configured", http.StatusOK, []int{http.StatusAccepted}, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, _ := newTestServer(t, test.status)
+			defer srv.Close()
+			svc, err := webhook.NewService("hook", webhook.Config{
+				URL:            srv.URL,
+				ExpectedStatus: test.expected,
+			}, nil)
+			if err != nil {
+				t.Fatalf("creating service failed: %v", err)
+			}
+
+			err = svc.Do()
+			if !test.fails {
+				if err != nil {
+					t.Fatalf("calling webhook failed: %v", err)
+				}
+				return
+			}
+			var statusErr *webhook.StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("expected status error, got %v", err)
+			}
+			if statusErr.StatusCode != test.status || statusErr.Body != "response body" {
+				t.Fatalf("unexpected status error: %+v", statusErr)
+			}
+			if services.IsRetryable(err) != test.retryable {
+				t.Fatalf("error retryable is %v, expect %v", services.IsRetryable(err), test.retryable)
+			}
+		})
+	}
+}
+
+// TestServiceUnreachable verifies that failed requests are retryable.
+func TestServiceUnreachable(t *testing.T) {
+	srv, _ := newTestServer(t, http.StatusOK)
+	url := srv.URL
+	srv.Close()
+	svc, err := webhook.NewService("hook", webhook.Config{URL: url}, nil)
+	if err != nil {
+		t.Fatalf("creating service failed: %v", err)
+	}
+	if err := svc.Do(); err == nil || !services.IsRetryable(err) {
+		t.Fatalf("expected retryable error, got %v", err)
+	}
+}
+
+// TestRegisterService verifies the registration of the webhook
+// service type and booking services by their specs.
+func TestRegisterService(t *testing.T) {
+	srv, requestC := newTestServer(t, http.StatusOK)
+	defer srv.Close()
+	catalog := services.NewCatalog()
+	if err := webhook.RegisterService(catalog, srv.Client()); err != nil {
+		t.Fatalf("registering service failed: %v", err)
+	}
+	config, err := json.Marshal(webhook.Config{URL: srv.URL, Body: "ping {{.ServiceID}}"})
+	if err != nil {
+		t.Fatalf("marshalling config failed: %v", err)
+	}
+	svc, err := catalog.Create(services.Spec{
+		ServiceID: "hook",
+		Type:      webhook.ServiceType,
+		Config:    config,
+	})
+	if err != nil {
+		t.Fatalf("creating service failed: %v", err)
+	}
+	if err := svc.Do(); err != nil {
+		t.Fatalf("calling webhook failed: %v", err)
+	}
+	if req := <-requestC; req.method != http.MethodPost || req.body != "ping hook" {
+		t.Fatalf("unexpected request: %s %s", req.method, req.body)
+	}
+
+	// Keys are snake case like in services.Spec.
+	svc, err = catalog.Create(services.Spec{
+		ServiceID: "signed",
+		Type:      webhook.ServiceType,
+		Config: json.RawMessage(`{"url":"` + srv.URL + `","secret":"secret",` +
+			`"signature_header":"X-Sig","expected_status":[200]}`),
+	})
+	if err != nil {
+		t.Fatalf("creating service failed: %v", err)
+	}
+	if err := svc.Do(); err != nil {
+		t.Fatalf("calling webhook failed: %v", err)
+	}
+	if req := <-requestC; req.header.Get("X-Sig") == "" {
+		t.Fatalf("signature header is missing")
+	}
+
+	_, err = catalog.Create(services.Spec{
+		ServiceID: "hook",
+		Type:      webhook.ServiceType,
+		Config:    json.RawMessage(`{"url":"http://localhost","body":"{{.Broken"}`),
+	})
+	if err == nil {
+		t.Fatalf("creating service with invalid template did not fail")
+	}
+}